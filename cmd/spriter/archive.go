@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/redforks/css/sprite"
+	"github.com/redforks/errors"
+)
+
+// Archive file and entry name inside it, parsed from -i and -o.
+type archivePath struct {
+	file, entry string
+	format      sprite.ArchiveFormat
+}
+
+// Parse p in archive:entry form, such as theme.zip:style.css, or an archive
+// file without entry, such as out.zip. Returns nil if p is not an archive.
+func parseArchivePath(p string) *archivePath {
+	for i := len(p) - 1; i > 0; i-- {
+		if p[i] != ':' {
+			continue
+		}
+		if format, ok := sprite.ArchiveFormatOf(p[:i]); ok {
+			return &archivePath{p[:i], path.Clean(p[i+1:]), format}
+		}
+	}
+	if format, ok := sprite.ArchiveFormatOf(p); ok {
+		return &archivePath{p, "", format}
+	}
+	return nil
+}
+
+// Returns relative path from directory from to directory to, both slash
+// separated entry names inside archive, "" if they are the same directory.
+func relEntryDir(from, to string) (string, error) {
+	rel, err := filepath.Rel(filepath.FromSlash(from), filepath.FromSlash(to))
+	if err != nil {
+		return "", errors.NewInput(err)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Read css entry of archive, returns it and Service reads images inside
+// archive relative to the css, creates generated files by out.
+func readArchiveInput(a *archivePath, out sprite.OutputService) ([]byte, sprite.Service, error) {
+	f, err := os.Open(a.file)
+	if err != nil {
+		return nil, nil, errors.NewInput(err)
+	}
+	defer f.Close()
+
+	fsys, err := sprite.ReadArchive(f, a.format)
+	if err != nil {
+		return nil, nil, err
+	}
+	css, err := fs.ReadFile(fsys, a.entry)
+	if err != nil {
+		return nil, nil, errors.NewInput(err)
+	}
+	return css, sprite.NewFSServiceDir(fsys, path.Dir(a.entry), out), nil
+}
+
+// Output archive file.
+type archiveOutput struct {
+	*sprite.ArchiveWriter
+	f *sprite.AtomicFile
+}
+
+func createArchive(a *archivePath, mode os.FileMode) (*archiveOutput, error) {
+	f, err := sprite.CreateAtomic(a.file, mode)
+	if err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+	return &archiveOutput{sprite.NewArchiveWriter(f, a.format), f}, nil
+}
+
+// Add file of content to archive.
+func (a *archiveOutput) writeFile(name string, content []byte) error {
+	w, err := a.CreateFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(content); err != nil {
+		return errors.NewRuntime(err)
+	}
+	if err = w.(interface{ Close() error }).Close(); err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+// Finish the archive and close the file, the file discarded if failed.
+func (a *archiveOutput) Close() error {
+	err := a.ArchiveWriter.Close()
+	if err != nil {
+		a.f.Abort()
+	} else {
+		err = a.f.Close()
+	}
+	if err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
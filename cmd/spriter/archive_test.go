@@ -0,0 +1,89 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("archive", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "spriter")
+		Ω(err).Should(Succeed())
+
+		buf := bytes.Buffer{}
+		Ω(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16)))).Should(Succeed())
+		writeZip(filepath.Join(dir, "theme.zip"), map[string][]byte{
+			"theme/css/style.css": []byte(`.a { background: url(../img/g.a.png); } .b { background: url(../img/bg.jpg); }`),
+			"theme/img/g.a.png":   buf.Bytes(),
+			"theme/img/bg.jpg":    nil,
+		})
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("Keep css path", func() {
+		out := filepath.Join(dir, "out.zip")
+		Ω(spriteMain([]string{"-i", filepath.Join(dir, "theme.zip") + ":theme/css/style.css", "-o", out})).Should(Succeed())
+
+		files := readZip(out)
+		Ω(files).Should(HaveKey("theme/css/style.css"))
+		css := string(files["theme/css/style.css"])
+		Ω(css).Should(MatchRegexp(`^\.a \{ background: url\(\.\./\.\./[^/]+\.png\) no-repeat; \} `))
+		Ω(css).Should(HaveSuffix(`.b { background: url(../img/bg.jpg); }`))
+	})
+
+	It("Rebase to css entry", func() {
+		out := filepath.Join(dir, "out.zip")
+		Ω(spriteMain([]string{"-i", filepath.Join(dir, "theme.zip") + ":theme/css/style.css", "-o", out + ":site.css"})).Should(Succeed())
+
+		files := readZip(out)
+		Ω(files).Should(HaveKey("site.css"))
+		css := string(files["site.css"])
+		Ω(css).Should(MatchRegexp(`^\.a \{ background: url\([^/]+\.png\) no-repeat; \} `))
+		Ω(css).Should(HaveSuffix(`.b { background: url(theme/img/bg.jpg); }`))
+	})
+
+})
+
+func writeZip(file string, files map[string][]byte) {
+	buf := bytes.Buffer{}
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		Ω(err).Should(Succeed())
+		_, err = w.Write(content)
+		Ω(err).Should(Succeed())
+	}
+	Ω(zw.Close()).Should(Succeed())
+	Ω(ioutil.WriteFile(file, buf.Bytes(), 0644)).Should(Succeed())
+}
+
+// Returns content of files in zip file keyed by name.
+func readZip(file string) map[string][]byte {
+	zr, err := zip.OpenReader(file)
+	Ω(err).Should(Succeed())
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		Ω(err).Should(Succeed())
+		files[f.Name], err = ioutil.ReadAll(r)
+		Ω(err).Should(Succeed())
+		r.Close()
+	}
+	return files
+}
@@ -4,7 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"os"
+	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/redforks/css/sprite"
 	"github.com/redforks/errors"
@@ -24,41 +28,318 @@ func (bps *basePathSlice) Set(value string) error {
 
 func main() {
 	cmdline.Go(func() error {
-		var bps basePathSlice
-		srcCssFile := flag.String("i", "", "Input css file")
-		dstCssFile := flag.String("o", "", "Output css file, can be the same as input css file")
-		flag.Var(&bps, "base", "Base directory to resolve image files. Default to input css file directory. Can be specified multiple times, it is useful if the input css file is created by tools such as scss from multiple .css files in different directories.")
+		if len(os.Args) > 1 {
+			switch os.Args[1] {
+			case "gen":
+				return genMain(os.Args[2:])
+			case "serve":
+				return serveMain(os.Args[2:])
+			}
+		}
+		return spriteMain(os.Args[1:])
+	})
+}
 
-		flag.Parse()
+// Sprite images referenced by input css file, write out rewritten css.
+func spriteMain(args []string) error {
+	var (
+		bps  basePathSlice
+		opts options
+		fs   = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n       %s gen [flags]\n       %s serve [flags]\n", os.Args[0], os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	srcCssFile := fs.String("i", "", "Input css file, or css file inside zip or tar archive in archive:file form, such as theme.zip:style.css, images are read from the archive.")
+	dstCssFile := fs.String("o", "", "Output css file, can be the same as input css file. Optional if -scss or -less specified. Output to zip or tar archive if ends with .zip, .tar, .tar.gz or .tgz, such as out.zip or out.zip:site.css, sprites and other generated files are written into the archive.")
+	fs.Var(&bps, "base", "Base directory to resolve image files. Default to input css file directory. Can be specified multiple times, it is useful if the input css file is created by tools such as scss from multiple .css files in different directories.")
+	keepQuery := fs.Bool("keep-query", false, "Append query string of the original image url to the sprite url, such as ?v=3 used for cache busting.")
+	shareBase := fs.Bool("share-base", false, "Emit one rule per sprite carrying the sprite url for all its selectors, reduce each declaration to background-position.")
+	watch := fs.Bool("watch", false, "Keep running, regenerate when input css file or images referenced by it changed.")
+	watchInterval := fs.Duration("watch-interval", 500*time.Millisecond, "Polling interval of -watch, regenerate after files unchanged for an interval.")
+	opts.register(fs)
+	fs.Parse(args)
 
+	if *srcCssFile == "" || *dstCssFile == "" && opts.scss == "" && opts.less == "" {
+		fs.Usage()
+		return cmdline.NewExitError(2)
+	}
+	if msg := opts.misuse(); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+		return cmdline.NewExitError(2)
+	}
+
+	in, out := parseArchivePath(*srcCssFile), parseArchivePath(*dstCssFile)
+	switch {
+	case in != nil && in.entry == "":
+		fmt.Fprintln(os.Stderr, "-i archive requires css file inside it, such as theme.zip:style.css")
+		return cmdline.NewExitError(2)
+	case in != nil && len(bps) != 0:
+		fmt.Fprintln(os.Stderr, "-base not supported with archive input, images are resolved inside the archive")
+		return cmdline.NewExitError(2)
+	case out != nil && opts.prune != "":
+		fmt.Fprintln(os.Stderr, "-prune not supported with archive output")
+		return cmdline.NewExitError(2)
+	case out != nil && opts.imgDir != "":
+		fmt.Fprintln(os.Stderr, "-img-dir not supported with archive output, sprites are saved at the root of the archive")
+		return cmdline.NewExitError(2)
+	case *watch && (in != nil || out != nil):
+		fmt.Fprintln(os.Stderr, "-watch not supported with archives")
+		return cmdline.NewExitError(2)
+	case *watch && sameFile(*srcCssFile, *dstCssFile):
+		fmt.Fprintln(os.Stderr, "-watch requires output css file different from input css file")
+		return cmdline.NewExitError(2)
+	}
+
+	// directory of the css file referencing sprites, sprite urls are
+	// relative to it.
+	cssDir := filepath.Dir(*dstCssFile)
+	switch {
+	case out != nil:
+		// everything generated goes into the archive, file options are
+		// entry names inside it.
+		if out.entry == "" {
+			out.entry = filepath.Base(*srcCssFile)
+			if in != nil {
+				out.entry = in.entry
+			}
+		}
+		cssDir, opts.imgDir, opts.archive = path.Dir(out.entry), ".", true
+	case *dstCssFile != "":
+	case opts.scss != "":
+		cssDir = filepath.Dir(opts.scss)
+	default:
+		cssDir = filepath.Dir(opts.less)
+	}
+
+	modeFrom := *srcCssFile
+	if in != nil {
+		modeFrom = in.file
+	}
+	if err := opts.resolveMode(modeFrom); err != nil {
+		return err
+	}
+
+	if len(bps) == 0 && in == nil {
+		bps = basePathSlice{filepath.Dir(*srcCssFile)}
+	}
+
+	// Build once, returns images referenced by input css file.
+	build := func() (images []string, err error) {
 		var (
-			css []byte
-			out string
-			err error
+			css     []byte
+			content string
+			diags   []*sprite.Diagnostic
+			ao      *archiveOutput
 		)
 
-		if *srcCssFile == "" || *dstCssFile == "" {
-			flag.Usage()
-			return cmdline.NewExitError(2)
+		files, err := opts.newService(([]string)(bps), cssDir)
+		if err != nil {
+			return nil, err
+		}
+		sv := files
+		if out != nil {
+			if ao, err = createArchive(out, opts.perm); err != nil {
+				return nil, err
+			}
+			defer func() {
+				if err != nil {
+					ao.f.Abort()
+					return
+				}
+				err = ao.Close()
+			}()
+			sv = sprite.WithOutput(sv, ao)
+		}
+		if in != nil {
+			css, sv, err = readArchiveInput(in, sv)
+		} else if css, err = ioutil.ReadFile(*srcCssFile); err != nil {
+			err = errors.NewInput(err)
 		}
+		if err != nil {
+			return nil, err
+		}
+
+		spriter := sprite.New(string(css), sv)
+		spriter.KeepQuery = *keepQuery
+		spriter.ShareBase = *shareBase
+		dst := *dstCssFile
+		if out != nil {
+			dst = out.entry
+		}
+		if err = opts.apply(spriter, cssDir, dst); err != nil {
+			return nil, err
+		}
+		// urls are rebased only if both css files on file system, or both
+		// inside archives.
+		switch {
+		case in == nil && out == nil:
+			spriter.Rebase, err = relDir(cssDir, filepath.Dir(*srcCssFile))
+		case in != nil && out != nil:
+			spriter.Rebase, err = relEntryDir(cssDir, path.Dir(in.entry))
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts.loadCache(spriter)
 
-		if len(bps) == 0 {
-			bps = basePathSlice{filepath.Dir(*srcCssFile)}
+		content, diags, err = spriter.Gen()
+		images = spriter.Images()
+		printDiagnostics(*srcCssFile, diags)
+		if err != nil {
+			return images, err
 		}
 
-		if css, err = ioutil.ReadFile(*srcCssFile); err != nil {
-			return errors.NewInput(err)
+		switch {
+		case ao != nil:
+			err = ao.writeFile(out.entry, ([]byte)(content))
+		case *dstCssFile != "":
+			if err = sprite.WriteFileAtomic(*dstCssFile, ([]byte)(content), opts.perm); err != nil {
+				err = errors.NewRuntime(err)
+			}
+		}
+		if err != nil {
+			return images, err
 		}
 
-		spriter := sprite.New(string(css), sprite.NewFileService(([]string)(bps), filepath.Dir(*dstCssFile)))
-		if out, err = spriter.Gen(); err != nil {
-			return err
+		if err = opts.runPrune(spriter, files); err != nil {
+			return images, err
+		}
+		if err = opts.saveCache(spriter); err != nil {
+			return images, err
 		}
+		return images, opts.writeStats(spriter)
+	}
 
-		if err = ioutil.WriteFile(*dstCssFile, ([]byte)(out), 0); err != nil {
-			return errors.NewRuntime(err)
+	if !*watch {
+		_, err := build()
+		return genError(err)
+	}
+
+	watchFiles(*watchInterval, []string{*srcCssFile}, func() []string {
+		images, err := build()
+		if err != nil {
+			reportError(err)
+		} else {
+			log.Printf("regenerated from %s", *srcCssFile)
 		}
 
-		return nil
+		files := []string{*srcCssFile}
+		for _, img := range images {
+			for _, bp := range bps {
+				files = append(files, filepath.Join(bp, filepath.FromSlash(img)))
+			}
+		}
+		return files
 	})
+	return nil
+}
+
+// Sprite all images in directories, write out a fresh stylesheet.
+func genMain(args []string) error {
+	var (
+		dirs basePathSlice
+		opts options
+		fs   = flag.NewFlagSet(os.Args[0]+" gen", flag.ExitOnError)
+	)
+	fs.Var(&dirs, "dir", "Directory of images, searched recursively. Can be specified multiple times.")
+	dstCssFile := fs.String("o", "", "Output css file")
+	opts.registerGen(fs)
+	opts.register(fs)
+	fs.Parse(args)
+
+	if len(dirs) == 0 || *dstCssFile == "" {
+		fs.Usage()
+		return cmdline.NewExitError(2)
+	}
+	if msg := opts.misuse(); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+		return cmdline.NewExitError(2)
+	}
+
+	if err := opts.resolveMode(""); err != nil {
+		return err
+	}
+	cssDir := filepath.Dir(*dstCssFile)
+	sv, err := opts.newService(([]string)(dirs), cssDir)
+	if err != nil {
+		return err
+	}
+	spriter, err := sprite.NewFromImages(sv, opts.selector, opts.baseSelector)
+	if err != nil {
+		return err
+	}
+	if err = opts.apply(spriter, cssDir, *dstCssFile); err != nil {
+		return err
+	}
+	opts.loadCache(spriter)
+
+	out, diags, err := spriter.Gen()
+	printDiagnostics("", diags)
+	if err != nil {
+		return genError(err)
+	}
+
+	if err = sprite.WriteFileAtomic(*dstCssFile, ([]byte)(out), opts.perm); err != nil {
+		return errors.NewRuntime(err)
+	}
+	if err = opts.runPrune(spriter, sv); err != nil {
+		return err
+	}
+	if err = opts.saveCache(spriter); err != nil {
+		return err
+	}
+	return opts.writeStats(spriter)
+}
+
+// Exit codes of errors returned by Spriter.Gen().
+const (
+	exitDiagnostic = 1
+	exitBudget     = 3
+)
+
+// Converts error returned by Spriter.Gen() to exit error, Error diagnostic
+// already printed exits with exitDiagnostic, sprite exceeds budget exits with
+// exitBudget. Other errors returned as is.
+func genError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	inner := err
+	if e, ok := err.(*errors.Error); ok {
+		inner = e.Err
+	}
+
+	switch inner.(type) {
+	case *sprite.Diagnostic:
+		return cmdline.NewExitError(exitDiagnostic)
+	case *sprite.BudgetError:
+		fmt.Fprintln(os.Stderr, inner)
+		return cmdline.NewExitError(exitBudget)
+	}
+	return err
+}
+
+// Report error of a build in watch mode, Error diagnostics already printed.
+func reportError(err error) {
+	inner := err
+	if e, ok := err.(*errors.Error); ok {
+		inner = e.Err
+	}
+	if _, ok := inner.(*sprite.Diagnostic); !ok {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Print diagnostics to stderr in file:line:col form.
+func printDiagnostics(file string, diags []*sprite.Diagnostic) {
+	for _, d := range diags {
+		if file == "" {
+			fmt.Fprintln(os.Stderr, d)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s:%s\n", file, d)
+	}
 }
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/redforks/css/sprite"
+	"github.com/redforks/errors"
+)
+
+// Spriter options shared by sub commands.
+type options struct {
+	strict bool
+
+	imgDir, urlPrefix string
+
+	nameTemplate, hashAlgorithm, hashEncoding string
+
+	order string
+
+	manifest, atlas, scss, less, report string
+
+	stats     bool
+	statsJSON string
+
+	budget       string
+	groupBudgets basePathSlice
+	totalBudget  string
+
+	cache string
+
+	// selector templates of gen command
+	gen                    bool
+	selector, baseSelector string
+
+	prune       string
+	pruneDryRun bool
+
+	mode string
+	perm os.FileMode // permission of created files, see resolveMode()
+
+	// output to archive, file options are entry names inside it.
+	archive bool
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.strict, "strict", false, "Refuse image files outside of base directories, such as url(../../g.x.png).")
+
+	fs.StringVar(&o.imgDir, "img-dir", "", "Directory to save sprite images. Default to output css file directory.")
+	fs.StringVar(&o.urlPrefix, "url-prefix", "", "Url prefix of sprite images in output css, such as /img/ or https://cdn.example.com/img/. Default to relative path from output css file to -img-dir.")
+
+	o.registerSprite(fs)
+
+	fs.StringVar(&o.manifest, "manifest", "", "Write JSON manifest of generated sprites and image coordinates to this file.")
+	fs.StringVar(&o.atlas, "atlas", "", "Comma separated texture atlas formats exported alongside sprite images: json-hash, json-array, sparrow.")
+	fs.StringVar(&o.scss, "scss", "", "Write SCSS partial defining variables and mixins of sprites to this file.")
+	fs.StringVar(&o.less, "less", "", "Write Less file defining variables and mixins of sprites to this file.")
+	fs.StringVar(&o.report, "report", "", "Write HTML catalogue of generated sprites to this file.")
+
+	fs.StringVar(&o.budget, "budget", "", "Budget of every sprite, build fails with exit code 3 if exceeded. Comma separated limits: bytes=N, width=N, height=N, stamps=N, such as bytes=102400,stamps=50.")
+	fs.Var(&o.groupBudgets, "group-budget", "Budget of a sprite group overriding -budget, in group:limits format, such as grp1:bytes=51200. Can be specified multiple times.")
+	fs.StringVar(&o.totalBudget, "total-budget", "", "Budget of all sprites together, in -budget format, build fails with exit code 3 if exceeded. bytes and stamps are summed over sprites, width and height apply to the largest sprite.")
+
+	fs.StringVar(&o.cache, "cache", "", "Incremental build cache file, such as .spriter-cache.json. Sprites whose images unchanged since last build are not regenerated.")
+
+	fs.StringVar(&o.prune, "prune", "", "Remove stale sprite images and atlas files in -img-dir generated by previous builds, tracked in this manifest file, such as .spriter-sprites.json. Files not recorded in it are never removed.")
+	fs.BoolVar(&o.pruneDryRun, "prune-dry-run", false, "With -prune, list stale files instead of removing them.")
+
+	fs.StringVar(&o.mode, "mode", "", "Octal permission of generated files, such as 0640. Default to permission of input css file, or 0644.")
+
+	fs.BoolVar(&o.stats, "stats", false, "Print build statistics of each sprite: image count, requests saved, source and sprite bytes, dimensions, wasted transparent area and timing.")
+	fs.StringVar(&o.statsJSON, "stats-json", "", "Write build statistics as JSON to this file, - for stdout.")
+}
+
+// Register options of gen command.
+func (o *options) registerGen(fs *flag.FlagSet) {
+	o.gen = true
+	fs.StringVar(&o.selector, "selector", sprite.DefaultSelector, "Selector template of each image, placeholders: [group], [name].")
+	fs.StringVar(&o.baseSelector, "base-selector", sprite.DefaultBaseSelector, "Selector template of the base class of each group carrying the sprite url, placeholder: [group].")
+}
+
+// Register options affecting sprite images, also used by serve command.
+func (o *options) registerSprite(fs *flag.FlagSet) {
+	fs.StringVar(&o.nameTemplate, "name", sprite.DefaultNameTemplate, "Sprite file name template, placeholders: [group], [hash], [hash:N] (first N characters of hash), [ext].")
+	fs.StringVar(&o.hashAlgorithm, "hash", "md5", "Hash algorithm used in sprite file name: md5, sha1 or sha256.")
+	fs.StringVar(&o.hashEncoding, "hash-encoding", "base64", "Encoding of hash in sprite file name: hex or base64.")
+
+	fs.StringVar(&o.order, "order", "css", "Order of images inside sprite: css (order referenced in css), name or size. name and size keep sprite unchanged when css rules are reordered.")
+}
+
+// Create file Service resolves images in srcPaths, saves sprites to -img-dir,
+// which defaults to cssDir.
+func (o *options) newService(srcPaths []string, cssDir string) (sprite.Service, error) {
+	if o.imgDir == "" {
+		o.imgDir = cssDir
+	}
+	if err := os.MkdirAll(o.imgDir, 0755); err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+
+	return sprite.NewFileServiceMode(srcPaths, o.imgDir, o.strict, o.perm), nil
+}
+
+// Returns message if options misused together, "" if fine.
+func (o *options) misuse() string {
+	switch {
+	case o.pruneDryRun && o.prune == "":
+		return "-prune-dry-run requires -prune"
+	case o.nameTemplate == "":
+		return "-name must not be empty"
+	case o.gen && o.selector == "":
+		return "-selector must not be empty"
+	case o.gen && o.baseSelector == "":
+		return "-base-selector must not be empty"
+	}
+	return ""
+}
+
+// Resolve permission of generated files: -mode if specified, otherwise
+// permission of input file, or sprite.DefaultFileMode if input is "".
+func (o *options) resolveMode(input string) error {
+	if o.mode != "" {
+		m, err := strconv.ParseUint(o.mode, 8, 32)
+		if err != nil || m&^uint64(os.ModePerm) != 0 {
+			return errors.Inputf("bad -mode %q, should be octal permission such as 0644", o.mode)
+		}
+		o.perm = os.FileMode(m)
+		return nil
+	}
+
+	o.perm = sprite.DefaultFileMode
+	if input == "" {
+		return nil
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return errors.NewInput(err)
+	}
+	o.perm = info.Mode().Perm()
+	return nil
+}
+
+// Apply options to spriter, cssDir is the directory of the css file
+// referencing sprites, cssFile is the output css file, can be empty.
+func (o *options) apply(spriter *sprite.Spriter, cssDir, cssFile string) (err error) {
+	if spriter.ManifestFile, err = o.outPath(o.manifest); err != nil {
+		return
+	}
+	if spriter.SCSSFile, err = o.outPath(o.scss); err != nil {
+		return
+	}
+	if spriter.LessFile, err = o.outPath(o.less); err != nil {
+		return
+	}
+	if spriter.ReportFile, err = o.outPath(o.report); err != nil {
+		return
+	}
+	if o.report != "" && cssFile != "" {
+		var rel string
+		if rel, err = relDir(filepath.Dir(o.report), filepath.Dir(cssFile)); err != nil {
+			return
+		}
+		spriter.ReportStylesheet = path.Join(rel, filepath.Base(cssFile))
+	}
+	if spriter.Budget, err = sprite.ParseBudget(o.budget); err != nil {
+		return
+	}
+	if spriter.GroupBudgets, err = parseGroupBudgets(o.groupBudgets); err != nil {
+		return
+	}
+	if spriter.TotalBudget, err = sprite.ParseBudget(o.totalBudget); err != nil {
+		return
+	}
+	if spriter.AtlasFormats, err = parseAtlasFormats(o.atlas); err != nil {
+		return
+	}
+	if err = o.applySprite(spriter); err != nil {
+		return
+	}
+	if spriter.URLPrefix = o.urlPrefix; spriter.URLPrefix == "" {
+		if spriter.URLPrefix, err = relDir(cssDir, o.imgDir); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// Fingerprint of options affecting sprite images, cache of other options
+// discarded.
+func (o *options) cacheOptions() string {
+	imgDir, _ := filepath.Abs(o.imgDir)
+	return strings.Join([]string{o.nameTemplate, o.hashAlgorithm, o.hashEncoding, imgDir}, "\n")
+}
+
+// Load -cache file into spriter.Cache. Starts a fresh cache if the file not
+// exist or is broken.
+func (o *options) loadCache(spriter *sprite.Spriter) {
+	if o.cache == "" {
+		return
+	}
+
+	spriter.Cache = sprite.NewCache(o.cacheOptions())
+	f, err := os.Open(o.cache)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("ignore cache: %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if c, err := sprite.ReadCache(f, o.cacheOptions()); err != nil {
+		log.Printf("ignore cache %s: %v", o.cache, err)
+	} else {
+		spriter.Cache = c
+	}
+}
+
+// Save spriter.Cache to -cache file.
+func (o *options) saveCache(spriter *sprite.Spriter) error {
+	if o.cache == "" {
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+	if err := sprite.WriteCache(&buf, spriter.Cache); err != nil {
+		return err
+	}
+	if err := sprite.WriteFileAtomic(o.cache, buf.Bytes(), o.perm); err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+// Remove stale sprites tracked by -prune manifest through sv, or list them if
+// -prune-dry-run, then record sprites generated by spriter.
+func (o *options) runPrune(spriter *sprite.Spriter, sv sprite.Service) error {
+	if o.prune == "" {
+		return nil
+	}
+	remover, ok := sv.(sprite.FileRemover)
+	if !ok {
+		return errors.Bug("Service not implement FileRemover")
+	}
+
+	dir, err := filepath.Abs(o.imgDir)
+	if err != nil {
+		return errors.NewRuntime(err)
+	}
+	m := sprite.NewPruneManifest(dir)
+	if f, err := os.Open(o.prune); err == nil {
+		m, err = sprite.ReadPruneManifest(f, dir)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.NewInput(err)
+	}
+
+	stale, err := m.Prune(remover, spriter.Generated(), o.pruneDryRun)
+	for _, f := range stale {
+		f = filepath.Join(o.imgDir, filepath.FromSlash(f))
+		if o.pruneDryRun {
+			fmt.Println(f)
+		} else {
+			log.Printf("removed %s", f)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	if err = sprite.WritePruneManifest(&buf, m); err != nil {
+		return err
+	}
+	if err = sprite.WriteFileAtomic(o.prune, buf.Bytes(), o.perm); err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+// Print or write build statistics of spriter as requested by -stats and
+// -stats-json.
+func (o *options) writeStats(spriter *sprite.Spriter) error {
+	if o.stats {
+		if err := sprite.WriteStats(os.Stdout, spriter.Stats()); err != nil {
+			return errors.NewRuntime(err)
+		}
+	}
+
+	if o.statsJSON == "" {
+		return nil
+	}
+	buf, err := json.MarshalIndent(spriter.Stats(), "", "  ")
+	if err != nil {
+		return errors.NewBug(err)
+	}
+	buf = append(buf, '\n')
+	if o.statsJSON == "-" {
+		_, err = os.Stdout.Write(buf)
+	} else {
+		err = sprite.WriteFileAtomic(o.statsJSON, buf, o.perm)
+	}
+	if err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+// Apply options registered by registerSprite() to spriter.
+func (o *options) applySprite(spriter *sprite.Spriter) (err error) {
+	if spriter.Order, err = sprite.ParseStampOrder(o.order); err != nil {
+		return
+	}
+	spriter.Namer, err = sprite.NewTemplateNamer(o.nameTemplate, o.hashAlgorithm, o.hashEncoding)
+	return
+}
+
+// Returns path of generated file p passed to Service: slash separated entry
+// name if output to archive, otherwise absolute path.
+func (o *options) outPath(p string) (string, error) {
+	if o.archive {
+		return filepath.ToSlash(p), nil
+	}
+	return absPath(p)
+}
+
+// Returns absolute path of p, "" if p is empty.
+func absPath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", errors.NewRuntime(err)
+	}
+	return abs, nil
+}
+
+// Returns slash separated relative path from directory from to directory to,
+// "" if they are the same directory.
+func relDir(from, to string) (string, error) {
+	from, err := filepath.Abs(from)
+	if err != nil {
+		return "", errors.NewRuntime(err)
+	}
+	to, err = filepath.Abs(to)
+	if err != nil {
+		return "", errors.NewRuntime(err)
+	}
+
+	rel, err := filepath.Rel(from, to)
+	if err != nil {
+		return "", errors.NewInput(err)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Parse group budgets in group:limits format.
+func parseGroupBudgets(items []string) (map[string]sprite.Budget, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	budgets := make(map[string]sprite.Budget)
+	for _, item := range items {
+		i := strings.IndexByte(item, ':')
+		if i <= 0 {
+			return nil, errors.Inputf("bad group budget %s, expect group:limits", item)
+		}
+		b, err := sprite.ParseBudget(item[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		budgets[item[:i]] = b
+	}
+	return budgets, nil
+}
+
+// Parse comma separated atlas format names.
+func parseAtlasFormats(names string) ([]sprite.AtlasFormat, error) {
+	var formats []sprite.AtlasFormat
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		f, err := sprite.ParseAtlasFormat(name)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, f)
+	}
+	return formats, nil
+}
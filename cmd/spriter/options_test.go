@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("options", func() {
+
+	DescribeTable("misuse", func(gen bool, args []string, exp string) {
+		var opts options
+		fs := flag.NewFlagSet("spriter", flag.ContinueOnError)
+		if gen {
+			opts.registerGen(fs)
+		}
+		opts.register(fs)
+		Ω(fs.Parse(args)).Should(Succeed())
+		Ω(opts.misuse()).Should(Equal(exp))
+	},
+		Entry("fine", true, nil, ""),
+		Entry("empty selector", true, []string{"-selector", ""}, "-selector must not be empty"),
+		Entry("empty base selector", true, []string{"-base-selector="}, "-base-selector must not be empty"),
+		Entry("empty name", false, []string{"-name", ""}, "-name must not be empty"),
+		Entry("dry run without prune", false, []string{"-prune-dry-run"}, "-prune-dry-run requires -prune"),
+	)
+
+})
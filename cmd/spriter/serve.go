@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/redforks/css/server"
+	"github.com/redforks/css/sprite"
+	"github.com/redforks/errors"
+	"github.com/redforks/errors/cmdline"
+)
+
+// Serve sprited css and sprite images over http, regenerated when sources
+// changed.
+func serveMain(args []string) error {
+	var (
+		bps  basePathSlice
+		opts options
+		fs   = flag.NewFlagSet(os.Args[0]+" serve", flag.ExitOnError)
+	)
+	srcCssFile := fs.String("i", "", "Input css file")
+	fs.Var(&bps, "base", "Base directory to resolve image files. Default to input css file directory. Can be specified multiple times.")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on.")
+	cssPath := fs.String("css-path", "", "Url path of rewritten css. Default to / + input css file name.")
+	spritePrefix := fs.String("sprite-prefix", "/sprites/", "Url path prefix of sprite images.")
+	keepQuery := fs.Bool("keep-query", false, "Append query string of the original image url to the sprite url.")
+	shareBase := fs.Bool("share-base", false, "Emit one rule per sprite carrying the sprite url for all its selectors, reduce each declaration to background-position.")
+	opts.registerSprite(fs)
+	fs.Parse(args)
+
+	if *srcCssFile == "" {
+		fs.Usage()
+		return cmdline.NewExitError(2)
+	}
+	if msg := opts.misuse(); msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+		return cmdline.NewExitError(2)
+	}
+
+	// validate options once, copied to each Spriter
+	proto := sprite.New("", nil)
+	if err := opts.applySprite(proto); err != nil {
+		return err
+	}
+	proto.KeepQuery, proto.ShareBase = *keepQuery, *shareBase
+
+	h := server.New(*srcCssFile, bps)
+	if *cssPath != "" {
+		h.CSSPath = *cssPath
+	}
+	h.SpritePrefix = *spritePrefix
+	h.Setup = func(s *sprite.Spriter) {
+		s.Order, s.Namer = proto.Order, proto.Namer
+		s.KeepQuery, s.ShareBase = proto.KeepQuery, proto.ShareBase
+	}
+
+	log.Printf("serving %s at http://%s%s", *srcCssFile, *addr, h.CSSPath)
+	log.Printf(`live reload: <script src="http://%s%s"></script>`, *addr, h.ScriptPath)
+	return errors.NewRuntime(http.ListenAndServe(*addr, h))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redforks/css/internal/poll"
+)
+
+// Poll files returned by build every interval, call build again if any of
+// them created, changed or deleted. Debounced: build is called after files
+// stay unchanged for an interval. build called once at start, returns files
+// to watch, files are known to be read by build, such as the input css file.
+// Never returns.
+func watchFiles(interval time.Duration, files []string, build func() []string) {
+	for {
+		// stat before build reads them, changes made during build not lost.
+		before := poll.TakeSnapshot(files)
+		files = build()
+		last := before.With(files)
+
+		cur := last
+		for cur.Equal(last) {
+			time.Sleep(interval)
+			cur = poll.TakeSnapshot(files)
+		}
+		for {
+			time.Sleep(interval)
+			next := poll.TakeSnapshot(files)
+			if next.Equal(cur) {
+				break
+			}
+			cur = next
+		}
+	}
+}
+
+// Returns true if a and b are the same file.
+func sameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if ia, err := os.Stat(a); err == nil {
+		if ib, err := os.Stat(b); err == nil {
+			return os.SameFile(ia, ib)
+		}
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,3 @@
+// Detects file changes by polling their modification time and size, shared by
+// the development server and `spriter -watch`.
+package poll
@@ -0,0 +1,79 @@
+package poll
+
+import "os"
+
+// FileState is the state of a file, zero value if not exist.
+type FileState struct {
+	ModTime int64 // in nanoseconds
+	Size    int64
+}
+
+// StatFile returns state of file f.
+func StatFile(f string) FileState {
+	info, err := os.Stat(f)
+	if err != nil {
+		return FileState{}
+	}
+	return FileState{info.ModTime().UnixNano(), info.Size()}
+}
+
+// Snapshot is the state of files keyed by path, to detect changes by
+// polling. Take it before reading the files, so that changes made while
+// reading are not lost.
+type Snapshot map[string]FileState
+
+// TakeSnapshot stats files.
+func TakeSnapshot(files []string) Snapshot {
+	r := make(Snapshot, len(files))
+	for _, f := range files {
+		r[f] = StatFile(f)
+	}
+	return r
+}
+
+// With returns Snapshot of files, reuses state recorded in s, files not in s
+// are stat now. Used after a build reported files it read, to keep state of
+// files known before the build.
+func (s Snapshot) With(files []string) Snapshot {
+	r := make(Snapshot, len(files))
+	for _, f := range files {
+		if st, ok := s[f]; ok {
+			r[f] = st
+		} else {
+			r[f] = StatFile(f)
+		}
+	}
+	return r
+}
+
+// Equal returns true if s and other have the same files in the same state.
+func (s Snapshot) Equal(other Snapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for f, st := range s {
+		if o, ok := other[f]; !ok || o != st {
+			return false
+		}
+	}
+	return true
+}
+
+// Changed returns true if any file in s created, changed or deleted since.
+func (s Snapshot) Changed() bool {
+	for f, st := range s {
+		if StatFile(f) != st {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns paths of files in s, unordered.
+func (s Snapshot) Files() []string {
+	r := make([]string, 0, len(s))
+	for f := range s {
+		r = append(r, f)
+	}
+	return r
+}
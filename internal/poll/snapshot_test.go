@@ -0,0 +1,64 @@
+package poll
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshot", func() {
+	var (
+		dir   string
+		mtime time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "poll")
+		Ω(err).Should(Succeed())
+		mtime = time.Now()
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	// Modification time increased each write, to make sure changes detected
+	// on coarse file systems.
+	writeFile := func(name string) {
+		p := filepath.Join(dir, name)
+		Ω(ioutil.WriteFile(p, nil, 0644)).Should(Succeed())
+		mtime = mtime.Add(time.Second)
+		Ω(os.Chtimes(p, mtime, mtime)).Should(Succeed())
+	}
+
+	It("Changed", func() {
+		a, b := filepath.Join(dir, "a.css"), filepath.Join(dir, "b.css")
+		s := TakeSnapshot([]string{a, b})
+		Ω(s[b]).Should(Equal(FileState{}))
+		Ω(s.Changed()).Should(BeFalse())
+
+		writeFile("b.css")
+		Ω(s.Changed()).Should(BeTrue())
+		Ω(s.Equal(TakeSnapshot([]string{a, b}))).Should(BeFalse())
+		Ω(TakeSnapshot([]string{a, b}).Equal(TakeSnapshot([]string{b, a}))).Should(BeTrue())
+	})
+
+	It("With", func() {
+		a, img := filepath.Join(dir, "a.css"), filepath.Join(dir, "g.a.png")
+		before := TakeSnapshot([]string{a})
+		writeFile("a.css")
+
+		s := before.With([]string{a, img})
+		Ω(s).Should(HaveLen(2))
+		Ω(s[a]).Should(Equal(before[a]))
+		Ω(s[img]).Should(Equal(StatFile(img)))
+		Ω(s.Changed()).Should(BeTrue())
+		Ω(s.Files()).Should(ConsistOf(a, img))
+	})
+
+})
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Server-Sent Events endpoint, sends "css" event with ETag of css as data
+// each time regeneration produces new css, "build-error" event with the error
+// message if regeneration failed.
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	interval := h.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := h.generate(true)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		g := h.generate(true)
+		if g == last {
+			continue
+		}
+		switch {
+		case g.err != nil:
+			writeEvent(w, "build-error", g.err.Error())
+		case last.css == nil || g.css.etag != last.css.etag:
+			writeEvent(w, "css", g.css.etag)
+		}
+		last = g
+		flusher.Flush()
+	}
+}
+
+// Write an event, multiple lines of data sent as multiple data fields.
+func writeEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(strings.TrimRight(data, "\n"), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// Script returns javascript reloading the stylesheet linked to CSSPath when
+// css regenerated, by listening to EventsPath. Inject it to development pages
+// by <script src="[ScriptPath]"></script>, or inline it.
+func (h *Handler) Script() string {
+	events, _ := json.Marshal(h.EventsPath)
+	css, _ := json.Marshal(h.CSSPath)
+	return fmt.Sprintf(liveReloadScript, events, css)
+}
+
+// DefaultPollInterval is the default interval of checking sources changed for
+// live reload.
+const DefaultPollInterval = 500 * time.Millisecond
+
+const liveReloadScript = `(function() {
+  var events = new EventSource(%s), cssPath = %s;
+  events.addEventListener("css", function(e) {
+    var links = document.querySelectorAll("link[rel=stylesheet]");
+    for (var i = 0; i < links.length; i++) {
+      var url = new URL(links[i].href, location.href);
+      if (url.pathname === cssPath) {
+        url.searchParams.set("v", JSON.parse(e.data));
+        links[i].href = url.href;
+      }
+    }
+  });
+  events.addEventListener("build-error", function(e) {
+    console.error("spriter: " + e.data);
+  });
+})();
+`
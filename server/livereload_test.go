@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Live reload", func() {
+	var (
+		ts     *httptest.Server
+		resp   *http.Response
+		events chan string
+	)
+
+	BeforeEach(func() {
+		h.PollInterval = 10 * time.Millisecond
+		ts = httptest.NewServer(h)
+
+		var err error
+		resp, err = http.Get(ts.URL + "/spriter/events")
+		Ω(err).Should(Succeed())
+		Ω(resp.Header.Get("Content-Type")).Should(Equal("text/event-stream"))
+
+		// send each event as lines joined by "|"
+		ch := make(chan string, 10)
+		events = ch
+		go func() {
+			defer GinkgoRecover()
+			var lines []string
+			sc := bufio.NewScanner(resp.Body)
+			for sc.Scan() {
+				if sc.Text() != "" {
+					lines = append(lines, sc.Text())
+					continue
+				}
+				ch <- strings.Join(lines, "|")
+				lines = nil
+			}
+			close(ch)
+		}()
+	})
+
+	AfterEach(func() {
+		resp.Body.Close()
+		ts.Close()
+	})
+
+	It("Notify css changed", func() {
+		etag := get("/a.css").Header().Get("ETag")
+		Consistently(events, 50*time.Millisecond).ShouldNot(Receive())
+
+		writeImage("g.b.png", 8, 8, color.Black)
+		var event string
+		Eventually(events).Should(Receive(&event))
+		newETag := get("/a.css").Header().Get("ETag")
+		Ω(newETag).ShouldNot(Equal(etag))
+		Ω(event).Should(Equal("event: css|data: " + newETag))
+	})
+
+	It("Notify error", func() {
+		writeFile("a.css", []byte(".a { background: url(g.c.png); }\n.b { background: url(g.d.png); }"))
+		var event string
+		Eventually(events).Should(Receive(&event))
+		Ω(event).Should(HavePrefix("event: build-error|data: "))
+		Ω(event).Should(ContainSubstring("a.css:1:18: error: open image g.c.png"))
+
+		writeImage("g.c.png", 8, 8, color.Black)
+		writeImage("g.d.png", 8, 8, color.Black)
+		Eventually(events).Should(Receive(HavePrefix("event: css|data: ")))
+	})
+
+	It("Script", func() {
+		r, err := http.Get(ts.URL + "/spriter/livereload.js")
+		Ω(err).Should(Succeed())
+		defer r.Body.Close()
+		Ω(r.Header.Get("Content-Type")).Should(Equal("application/javascript; charset=utf-8"))
+		Ω(h.Script()).Should(ContainSubstring(`new EventSource("/spriter/events"), cssPath = "/a.css";`))
+	})
+
+})
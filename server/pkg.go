@@ -0,0 +1,5 @@
+// Contains Handler serving sprited css and sprite images from memory,
+// regenerated when sources changed, for development without a separate
+// build step.
+// For standalone server, see `spriter serve` command.
+package server
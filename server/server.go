@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redforks/css/internal/poll"
+	"github.com/redforks/css/sprite"
+)
+
+// Handler is an http.Handler serves css file rewritten by Spriter at CSSPath,
+// and sprite images under SpritePrefix, both from memory. Sources, the css
+// file and images referenced by it, are checked on each css request, css and
+// sprites regenerated if any of them changed.
+type Handler struct {
+	// CSSPath is the url path of rewritten css, default to "/" + css file
+	// name.
+	CSSPath string
+
+	// SpritePrefix is the url path prefix of sprite images, used as
+	// Spriter.URLPrefix, default to "/sprites/".
+	SpritePrefix string
+
+	// EventsPath is the url path of Server-Sent Events endpoint notifying
+	// browsers when css regenerated, default to "/spriter/events".
+	EventsPath string
+
+	// ScriptPath is the url path of live reload script, see Script(),
+	// default to "/spriter/livereload.js".
+	ScriptPath string
+
+	// PollInterval is the interval of checking sources while browsers
+	// listening to EventsPath, default to DefaultPollInterval.
+	PollInterval time.Duration
+
+	// Setup, if not nil, configures Spriter before each generation, such as
+	// setting Namer and Order. URLPrefix is overwritten by SpritePrefix.
+	Setup func(*sprite.Spriter)
+
+	cssFile   string
+	basePaths []string
+
+	mu  sync.Mutex
+	gen *generation
+}
+
+// New creates Handler.
+//
+//  cssFile: input css file
+//  basePaths: directories to resolve images, default to directory of cssFile
+func New(cssFile string, basePaths []string) *Handler {
+	if len(basePaths) == 0 {
+		basePaths = []string{filepath.Dir(cssFile)}
+	}
+	return &Handler{
+		CSSPath:      "/" + filepath.Base(cssFile),
+		SpritePrefix: "/sprites/",
+		EventsPath:   "/spriter/events",
+		ScriptPath:   "/spriter/livereload.js",
+		cssFile:      cssFile,
+		basePaths:    basePaths,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch p := r.URL.Path; {
+	case p == h.CSSPath:
+		g := h.generate(true)
+		if g.err != nil {
+			http.Error(w, g.err.Error(), http.StatusInternalServerError)
+			return
+		}
+		serveAsset(w, r, "text/css; charset=utf-8", g.css)
+	case p == h.EventsPath:
+		h.serveEvents(w, r)
+	case p == h.ScriptPath:
+		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		io.WriteString(w, h.Script())
+	case strings.HasPrefix(p, h.SpritePrefix):
+		a := h.generate(false).sprites[p[len(h.SpritePrefix):]]
+		if a == nil {
+			http.NotFound(w, r)
+			return
+		}
+		serveAsset(w, r, "image/png", a)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Returns current generation. Regenerates if not generated yet, or check is
+// true and sources changed.
+func (h *Handler) generate(check bool) *generation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.gen == nil || check && h.gen.sources.Changed() {
+		h.gen = h.build()
+	}
+	return h.gen
+}
+
+// Result of a generation.
+type generation struct {
+	css     *asset
+	sprites map[string]*asset // keyed by sprite file name
+	err     error             // generation failed if not nil
+
+	sources poll.Snapshot
+}
+
+// Generated file.
+type asset struct {
+	content []byte
+	etag    string
+}
+
+func newAsset(content []byte) *asset {
+	return &asset{content, `"` + etagNamer.Name("", content) + `"`}
+}
+
+// ETag is the hash of content, the same hash used in sprite file names.
+var etagNamer, _ = sprite.NewTemplateNamer("[hash]", "md5", "base64")
+
+func serveAsset(w http.ResponseWriter, r *http.Request, contentType string, a *asset) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", a.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(a.content))
+}
+
+func (h *Handler) build() *generation {
+	// stat sources before reading them, changes made while generating
+	// trigger the next generation.
+	known := []string{h.cssFile}
+	if h.gen != nil {
+		known = h.gen.sources.Files()
+	}
+	before := poll.TakeSnapshot(known)
+	g := &generation{sources: before.With([]string{h.cssFile})}
+
+	css, err := ioutil.ReadFile(h.cssFile)
+	if err != nil {
+		g.err = err
+		return g
+	}
+
+	mem := sprite.NewMemoryService(nil)
+	spriter := sprite.New(string(css), sprite.WithOutput(sprite.NewFileService(h.basePaths, ""), mem))
+	if h.Setup != nil {
+		h.Setup(spriter)
+	}
+	spriter.URLPrefix = h.SpritePrefix
+
+	out, diags, err := spriter.Gen()
+	files := []string{h.cssFile}
+	for _, img := range spriter.Images() {
+		for _, base := range h.basePaths {
+			files = append(files, filepath.Join(base, filepath.FromSlash(img)))
+		}
+	}
+	g.sources = before.With(files)
+	if err != nil {
+		g.err = diagnosticsError(h.cssFile, diags, err)
+		return g
+	}
+
+	g.css = newAsset([]byte(out))
+	sprites := mem.Sprites()
+	g.sprites = make(map[string]*asset, len(sprites))
+	for name, content := range sprites {
+		g.sprites[name] = newAsset(content)
+	}
+	return g
+}
+
+// Returns error listing diagnostics in file:line:col form, err if no
+// diagnostics.
+func diagnosticsError(file string, diags []*sprite.Diagnostic, err error) error {
+	if len(diags) == 0 {
+		return err
+	}
+
+	buf := strings.Builder{}
+	for _, d := range diags {
+		fmt.Fprintf(&buf, "%s:%s\n", file, d)
+	}
+	return fmt.Errorf("%s", buf.String())
+}
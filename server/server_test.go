@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/redforks/css/sprite"
+)
+
+var (
+	dir string
+	h   *Handler
+)
+
+// Each spec has a temp dir with g.a.png, g.b.png and a.css referencing them,
+// served by h.
+var _ = BeforeEach(func() {
+	var err error
+	dir, err = ioutil.TempDir("", "spriter")
+	Ω(err).Should(Succeed())
+
+	writeImage("g.a.png", 16, 16, color.Black)
+	writeImage("g.b.png", 8, 8, color.White)
+	writeFile("a.css", []byte(`.a { background: url(g.a.png); } .b { background: url(g.b.png); }`))
+	h = New(filepath.Join(dir, "a.css"), nil)
+})
+
+var _ = AfterEach(func() {
+	Ω(os.RemoveAll(dir)).Should(Succeed())
+})
+
+// Modification time of next written file, increased by writeFile() to make
+// sure changes detected on coarse file systems.
+var mtime = time.Now()
+
+func writeFile(name string, content []byte) {
+	p := filepath.Join(dir, name)
+	Ω(ioutil.WriteFile(p, content, 0644)).Should(Succeed())
+	mtime = mtime.Add(time.Second)
+	Ω(os.Chtimes(p, mtime, mtime)).Should(Succeed())
+}
+
+func writeImage(name string, w, h int, c color.Color) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, c)
+	buf := bytes.Buffer{}
+	Ω(png.Encode(&buf, img)).Should(Succeed())
+	writeFile(name, buf.Bytes())
+}
+
+func get(path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+var _ = Describe("Handler", func() {
+	spriteURL := regexp.MustCompile(`/sprites/[^)]+`)
+
+	It("Serve css and sprites", func() {
+		w := get("/a.css")
+		Ω(w.Code).Should(Equal(http.StatusOK))
+		Ω(w.Header().Get("Content-Type")).Should(Equal("text/css; charset=utf-8"))
+		Ω(w.Header().Get("ETag")).Should(MatchRegexp(`^"[-_A-Za-z0-9]{22}"$`))
+		css := w.Body.String()
+		Ω(css).Should(MatchRegexp(`^\.a \{ background: url\(/sprites/[-_A-Za-z0-9]{8}\.png\) no-repeat; \} \.b \{ background: url\(/sprites/[-_A-Za-z0-9]{8}\.png\) no-repeat -16px 0; \}$`))
+
+		w = get(spriteURL.FindString(css))
+		Ω(w.Code).Should(Equal(http.StatusOK))
+		Ω(w.Header().Get("Content-Type")).Should(Equal("image/png"))
+		Ω(w.Header().Get("ETag")).ShouldNot(BeEmpty())
+		config, err := png.DecodeConfig(w.Body)
+		Ω(err).Should(Succeed())
+		Ω(config.Width).Should(Equal(24))
+	})
+
+	It("Not modified", func() {
+		etag := get("/a.css").Header().Get("ETag")
+		Ω(get("/a.css", "If-None-Match", etag).Code).Should(Equal(http.StatusNotModified))
+	})
+
+	It("Not found", func() {
+		Ω(get("/sprites/foo.png").Code).Should(Equal(http.StatusNotFound))
+		Ω(get("/b.css").Code).Should(Equal(http.StatusNotFound))
+	})
+
+	It("Method not allowed", func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/a.css", nil))
+		Ω(w.Code).Should(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("Regenerate on change", func() {
+		w := get("/a.css")
+		etag, css := w.Header().Get("ETag"), w.Body.String()
+		Ω(get("/a.css").Header().Get("ETag")).Should(Equal(etag))
+
+		writeImage("g.b.png", 8, 8, color.Black)
+		w = get("/a.css")
+		Ω(w.Header().Get("ETag")).ShouldNot(Equal(etag))
+		Ω(w.Body.String()).ShouldNot(Equal(css))
+		Ω(get(spriteURL.FindString(css)).Code).Should(Equal(http.StatusNotFound))
+		Ω(get(spriteURL.FindString(w.Body.String())).Code).Should(Equal(http.StatusOK))
+	})
+
+	It("Report error", func() {
+		writeFile("a.css", []byte(`.a { background: url(g.c.png); }`))
+		w := get("/a.css")
+		Ω(w.Code).Should(Equal(http.StatusInternalServerError))
+		Ω(w.Body.String()).Should(ContainSubstring("a.css:1:18: error: open image g.c.png"))
+
+		writeImage("g.c.png", 8, 8, color.Black)
+		Ω(get("/a.css").Code).Should(Equal(http.StatusOK))
+	})
+
+	It("Setup and paths", func() {
+		h.CSSPath = "/css/site.css"
+		h.SpritePrefix = "/img/"
+		h.Setup = func(s *sprite.Spriter) {
+			s.Namer = sprite.NamerFunc(func(group string, _ []byte) string {
+				return group + ".png"
+			})
+		}
+		Ω(get("/css/site.css").Body.String()).Should(ContainSubstring("url(/img/g.png)"))
+		Ω(get("/img/g.png").Code).Should(Equal(http.StatusOK))
+	})
+
+	It("Change during generation not lost", func() {
+		builds := 0
+		h.Setup = func(*sprite.Spriter) {
+			builds++
+			if builds == 2 {
+				writeImage("g.a.png", 16, 16, color.White)
+			}
+		}
+		get("/a.css")
+		writeFile("a.css", []byte(`.a { background: url(g.a.png); }`))
+		get("/a.css")
+		Ω(builds).Should(Equal(2))
+
+		get("/a.css")
+		Ω(builds).Should(Equal(3))
+		get("/a.css")
+		Ω(builds).Should(Equal(3))
+	})
+
+})
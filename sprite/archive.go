@@ -0,0 +1,233 @@
+package sprite
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/redforks/errors"
+)
+
+// ArchiveFormat is the format of archive file.
+type ArchiveFormat int
+
+const (
+	// ArchiveZip is zip archive.
+	ArchiveZip ArchiveFormat = iota
+
+	// ArchiveTar is uncompressed tar archive.
+	ArchiveTar
+
+	// ArchiveTarGz is gzip compressed tar archive.
+	ArchiveTarGz
+)
+
+var archiveFormatNames = []string{"zip", "tar", "tar.gz"}
+
+func (f ArchiveFormat) String() string {
+	if f < 0 || int(f) >= len(archiveFormatNames) {
+		return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+	}
+	return archiveFormatNames[f]
+}
+
+// ArchiveFormatOf returns format of archive file by its name extension:
+// ".zip", ".tar", ".tar.gz" or ".tgz". Returns false if name is not an
+// archive.
+func ArchiveFormatOf(name string) (ArchiveFormat, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, true
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar, true
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, true
+	}
+	return ArchiveZip, false
+}
+
+// ReadArchive reads archive in format from r into memory, returns fs.FS of
+// its regular files. Use it with NewFSService() to read images from archive.
+func ReadArchive(r io.Reader, format ArchiveFormat) (fs.FS, error) {
+	var (
+		buf []byte
+		err error
+	)
+	if format == ArchiveZip {
+		buf, err = ioutil.ReadAll(r)
+	} else {
+		buf, err = tarToZip(r, format == ArchiveTarGz)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return nil, errors.NewInput(err)
+	}
+	return zr, nil
+}
+
+// Repack tar archive to uncompressed zip archive, to reuse fs.FS
+// implementation of zip.Reader.
+func tarToZip(r io.Reader, gzipped bool) ([]byte, error) {
+	if gzipped {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.NewInput(err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	buf := bytes.Buffer{}
+	zw := zip.NewWriter(&buf)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.NewInput(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     path.Clean(strings.TrimPrefix(hdr.Name, "/")),
+			Method:   zip.Store,
+			Modified: hdr.ModTime,
+		})
+		if err != nil {
+			return nil, errors.NewBug(err)
+		}
+		if _, err = io.Copy(w, tr); err != nil {
+			return nil, errors.NewInput(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.NewBug(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ArchiveWriter is an OutputService writes generated files into an archive.
+// Call Close() to finish the archive, the underlying io.Writer not closed.
+type ArchiveWriter struct {
+	zw      *zip.Writer
+	tw      *tar.Writer
+	gw      *gzip.Writer
+	written map[string]bool
+}
+
+// NewArchiveWriter creates ArchiveWriter writes archive in format to w.
+func NewArchiveWriter(w io.Writer, format ArchiveFormat) *ArchiveWriter {
+	a := &ArchiveWriter{written: make(map[string]bool)}
+	switch format {
+	case ArchiveZip:
+		a.zw = zip.NewWriter(w)
+	case ArchiveTarGz:
+		a.gw = gzip.NewWriter(w)
+		a.tw = tar.NewWriter(a.gw)
+	default:
+		a.tw = tar.NewWriter(w)
+	}
+	return a
+}
+
+// CreateSpriteImage implements OutputService interface.
+func (a *ArchiveWriter) CreateSpriteImage(path string) (io.Writer, error) {
+	return a.CreateFile(path)
+}
+
+// CreateFile implements FileCreator interface, path is the slash separated
+// file name inside archive. File is added to archive when the returned
+// io.Writer closed.
+func (a *ArchiveWriter) CreateFile(p string) (io.Writer, error) {
+	name := path.Clean(strings.TrimPrefix(p, "/"))
+	if !fs.ValidPath(name) {
+		return nil, errors.Inputf("bad file name in archive: %s", p)
+	}
+	return &archiveEntry{a: a, name: name}, nil
+}
+
+// SpriteExists implements SpriteChecker, returns true if sprite already
+// written to the archive.
+func (a *ArchiveWriter) SpriteExists(path string) bool {
+	return a.written[path]
+}
+
+// Close finishes the archive.
+func (a *ArchiveWriter) Close() error {
+	if a.zw != nil {
+		return a.zw.Close()
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gw != nil {
+		return a.gw.Close()
+	}
+	return nil
+}
+
+func (a *ArchiveWriter) writeEntry(name string, content []byte) error {
+	var (
+		w   io.Writer
+		err error
+	)
+	now := time.Now()
+	if a.zw != nil {
+		w, err = a.zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: now,
+		})
+	} else {
+		w, err = a.tw, a.tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  now,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(content); err != nil {
+		return err
+	}
+	a.written[name] = true
+	return nil
+}
+
+// File in ArchiveWriter, content buffered and written on Close(), because
+// tar header needs file size.
+type archiveEntry struct {
+	bytes.Buffer
+	a    *ArchiveWriter
+	name string
+}
+
+func (e *archiveEntry) Close() error {
+	return e.a.writeEntry(e.name, e.Bytes())
+}
+
+// Abort discards the entry, not added to archive.
+func (e *archiveEntry) Abort() error {
+	e.Reset()
+	return nil
+}
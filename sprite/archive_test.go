@@ -0,0 +1,140 @@
+package sprite
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"io/fs"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Archive", func() {
+
+	DescribeTable("ArchiveFormatOf", func(name string, exp ArchiveFormat, expOK bool) {
+		format, ok := ArchiveFormatOf(name)
+		Ω(ok).Should(Equal(expOK))
+		if ok {
+			Ω(format).Should(Equal(exp))
+		}
+	},
+		Entry("zip", "a/theme.ZIP", ArchiveZip, true),
+		Entry("tar", "theme.tar", ArchiveTar, true),
+		Entry("tar.gz", "theme.tar.gz", ArchiveTarGz, true),
+		Entry("tgz", "theme.tgz", ArchiveTarGz, true),
+		Entry("not archive", "theme.css", ArchiveZip, false),
+	)
+
+	It("Format String", func() {
+		Ω(ArchiveTarGz.String()).Should(Equal("tar.gz"))
+		Ω(ArchiveFormat(9).String()).Should(Equal("ArchiveFormat(9)"))
+	})
+
+	It("Bad file name", func() {
+		_, err := NewArchiveWriter(&bytes.Buffer{}, ArchiveZip).CreateFile("../a.css")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Bad archive", func() {
+		_, err := ReadArchive(bytes.NewReader([]byte("not zip")), ArchiveZip)
+		Ω(err).Should(HaveOccurred())
+		_, err = ReadArchive(bytes.NewReader([]byte("not tgz")), ArchiveTarGz)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Css in sub directory", func() {
+		buf := &bytes.Buffer{}
+		aw := NewArchiveWriter(buf, ArchiveZip)
+		for name, content := range map[string][]byte{
+			"theme/img/g1.t1.png": MustAsset("testdata/t1.png"),
+			"theme/css/style.css": []byte(".a { background: url(../img/g1.t1.png); }"),
+		} {
+			w, err := aw.CreateFile(name)
+			Ω(err).Should(Succeed())
+			w.Write(content)
+			Ω(w.(io.Closer).Close()).Should(Succeed())
+		}
+		Ω(aw.Close()).Should(Succeed())
+
+		fsys, err := ReadArchive(buf, ArchiveZip)
+		Ω(err).Should(Succeed())
+		css, err := fs.ReadFile(fsys, "theme/css/style.css")
+		Ω(err).Should(Succeed())
+		out := NewMemoryService(nil)
+		s := New(string(css), NewFSServiceDir(fsys, "theme/css", out))
+		_, diags, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(diags).Should(BeEmpty())
+		Ω(out.Sprites()).Should(HaveLen(1))
+	})
+
+	DescribeTable("Round trip", func(format ArchiveFormat) {
+		// write input archive
+		buf := &bytes.Buffer{}
+		aw := NewArchiveWriter(buf, format)
+		for name, content := range map[string][]byte{
+			"/css/img/g1.t1.png": MustAsset("testdata/t1.png"),
+			"css/g1.t2.png":      MustAsset("testdata/t2.png"),
+		} {
+			w, err := aw.CreateFile(name)
+			Ω(err).Should(Succeed())
+			w.Write(content)
+			Ω(w.(io.Closer).Close()).Should(Succeed())
+		}
+		Ω(aw.Close()).Should(Succeed())
+
+		fsys, err := ReadArchive(buf, format)
+		Ω(err).Should(Succeed())
+		Ω(fs.ReadFile(fsys, "css/g1.t2.png")).Should(Equal(MustAsset("testdata/t2.png")))
+
+		// sprite images inside archive, output to another archive
+		sub, err := fs.Sub(fsys, "css")
+		Ω(err).Should(Succeed())
+		buf = &bytes.Buffer{}
+		aw = NewArchiveWriter(buf, format)
+		s := New(`
+	.a { background: url(img/g1.t1.png); }
+	.b { background: url(g1.t2.png); }`, NewFSService(sub, aw))
+		s.ManifestFile = "sprites.json"
+		_, _, err = s.Gen()
+		Ω(err).Should(Succeed())
+		file := s.Sheets()[0].File
+		Ω(aw.SpriteExists(file)).Should(BeTrue())
+		Ω(aw.Close()).Should(Succeed())
+
+		fsys, err = ReadArchive(buf, format)
+		Ω(err).Should(Succeed())
+		Ω(fs.ReadFile(fsys, "sprites.json")).ShouldNot(BeEmpty())
+		sprite, err := fs.ReadFile(fsys, file)
+		Ω(err).Should(Succeed())
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(sprite))
+		Ω(err).Should(Succeed())
+		Ω([]int{cfg.Width, cfg.Height}).Should(Equal([]int{32, 16}))
+	},
+		Entry("zip", ArchiveZip),
+		Entry("tar", ArchiveTar),
+		Entry("tar.gz", ArchiveTarGz),
+	)
+
+})
+
+var _ = Describe("WithOutput", func() {
+
+	It("Gen", func() {
+		fsys := fstest.MapFS{"g1.t1.png": {Data: MustAsset("testdata/t1.png")}}
+		files := NewMemoryService(nil)
+		out := NewMemoryService(nil)
+		sv := WithOutput(NewFSService(fsys, files), out)
+		s := New(`.a { background: url(g1.t1.png); }`, sv)
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(files.Sprites()).Should(BeEmpty())
+		Ω(out.Sprites()).Should(HaveLen(1))
+		Ω(sv.(SpriteChecker).SpriteExists(s.Sheets()[0].File)).Should(BeTrue())
+		Ω(sv.(ImageLister).ListImages()).Should(Equal([]string{"g1.t1.png"}))
+	})
+
+})
@@ -0,0 +1,194 @@
+package sprite
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/redforks/errors"
+)
+
+// AtlasFormat is the texture atlas file format exported alongside sprite
+// image, for canvas and game engines.
+type AtlasFormat int
+
+const (
+	// AtlasJSONHash is TexturePacker JSON (Hash) format, frames keyed by
+	// image path. File extension ".json".
+	AtlasJSONHash AtlasFormat = iota
+
+	// AtlasJSONArray is TexturePacker JSON (Array) format. File extension
+	// ".array.json".
+	AtlasJSONArray
+
+	// AtlasSparrow is Sparrow/Starling XML format. File extension ".xml".
+	AtlasSparrow
+)
+
+var atlasFormatNames = []string{"json-hash", "json-array", "sparrow"}
+
+var atlasFormatExts = []string{".json", ".array.json", ".xml"}
+
+func (f AtlasFormat) String() string {
+	if f < 0 || int(f) >= len(atlasFormatNames) {
+		return fmt.Sprintf("AtlasFormat(%d)", int(f))
+	}
+	return atlasFormatNames[f]
+}
+
+// Ext returns atlas file extension of the format.
+func (f AtlasFormat) Ext() string {
+	return atlasFormatExts[f]
+}
+
+// ParseAtlasFormat parses AtlasFormat from its name: "json-hash",
+// "json-array" or "sparrow".
+func ParseAtlasFormat(name string) (AtlasFormat, error) {
+	for i, n := range atlasFormatNames {
+		if n == name {
+			return AtlasFormat(i), nil
+		}
+	}
+	return AtlasJSONHash, errors.Inputf("unknown atlas format %s", name)
+}
+
+// AtlasFile returns file name of sheet atlas in format, sprite file name with
+// its extension replaced by format.Ext().
+func AtlasFile(sheet *Sheet, format AtlasFormat) string {
+	return strings.TrimSuffix(sheet.File, path.Ext(sheet.File)) + format.Ext()
+}
+
+// WriteAtlas writes atlas of sheet in format to w. The atlas references sprite
+// image by its file name, assumes they are in the same directory.
+func WriteAtlas(w io.Writer, sheet *Sheet, format AtlasFormat) error {
+	var (
+		buf []byte
+		err error
+	)
+	switch format {
+	case AtlasJSONHash:
+		frames := make(map[string]*tpFrame)
+		for _, st := range sheet.Stamps {
+			frames[st.Path] = newTPFrame(st)
+		}
+		buf, err = json.MarshalIndent(&tpAtlas{frames, newTPMeta(sheet)}, "", "  ")
+	case AtlasJSONArray:
+		frames := make([]*tpFrame, 0, len(sheet.Stamps))
+		for _, st := range sheet.Stamps {
+			frame := newTPFrame(st)
+			frame.Filename = st.Path
+			frames = append(frames, frame)
+		}
+		buf, err = json.MarshalIndent(&tpAtlas{frames, newTPMeta(sheet)}, "", "  ")
+	case AtlasSparrow:
+		atlas := &sparrowAtlas{ImagePath: sheet.File}
+		for _, st := range sheet.Stamps {
+			atlas.SubTextures = append(atlas.SubTextures, &sparrowSubTexture{
+				st.Path, st.X, st.Y, st.Width, st.Height,
+			})
+		}
+		if buf, err = xml.MarshalIndent(atlas, "", "  "); err == nil {
+			buf = append([]byte(xml.Header), buf...)
+		}
+	default:
+		return errors.Bugf("unknown atlas format %s", format)
+	}
+	if err != nil {
+		return errors.NewBug(err)
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
+
+// Write atlas files of sheet in all AtlasFormats.
+func (s *Spriter) writeAtlases(sheet *Sheet) error {
+	for _, format := range s.AtlasFormats {
+		if err := s.writeAtlas(sheet, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Spriter) writeAtlas(sheet *Sheet, format AtlasFormat) (err error) {
+	f, err := s.createFile(AtlasFile(sheet, format))
+	if err != nil {
+		return err
+	}
+	defer closeWriter(f, &err)
+	return WriteAtlas(f, sheet, format)
+}
+
+// TexturePacker JSON
+type tpAtlas struct {
+	Frames interface{} `json:"frames"`
+	Meta   *tpMeta     `json:"meta"`
+}
+
+type tpRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpFrame struct {
+	Filename         string  `json:"filename,omitempty"`
+	Frame            *tpRect `json:"frame"`
+	Rotated          bool    `json:"rotated"`
+	Trimmed          bool    `json:"trimmed"`
+	SpriteSourceSize *tpRect `json:"spriteSourceSize"`
+	SourceSize       *tpSize `json:"sourceSize"`
+}
+
+type tpMeta struct {
+	App     string  `json:"app"`
+	Version string  `json:"version"`
+	Image   string  `json:"image"`
+	Format  string  `json:"format"`
+	Size    *tpSize `json:"size"`
+	Scale   string  `json:"scale"`
+}
+
+func newTPFrame(st *StampInfo) *tpFrame {
+	return &tpFrame{
+		Frame:            &tpRect{st.X, st.Y, st.Width, st.Height},
+		SpriteSourceSize: &tpRect{0, 0, st.Width, st.Height},
+		SourceSize:       &tpSize{st.Width, st.Height},
+	}
+}
+
+func newTPMeta(sheet *Sheet) *tpMeta {
+	return &tpMeta{
+		App:     "github.com/redforks/css/sprite",
+		Version: "1.0",
+		Image:   sheet.File,
+		Format:  "RGBA8888",
+		Size:    &tpSize{sheet.Width, sheet.Height},
+		Scale:   "1",
+	}
+}
+
+// Sparrow/Starling XML
+type sparrowAtlas struct {
+	XMLName     xml.Name             `xml:"TextureAtlas"`
+	ImagePath   string               `xml:"imagePath,attr"`
+	SubTextures []*sparrowSubTexture `xml:"SubTexture"`
+}
+
+type sparrowSubTexture struct {
+	Name   string `xml:"name,attr"`
+	X      int    `xml:"x,attr"`
+	Y      int    `xml:"y,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
@@ -0,0 +1,128 @@
+package sprite
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Atlas", func() {
+	sheet := &Sheet{
+		Group:  "g1",
+		File:   "g1.png",
+		URL:    "g1.png",
+		Width:  40,
+		Height: 24,
+		Stamps: []*StampInfo{
+			{"g1.a.png", "a", 0, 0, 24, 24, nil},
+			{"img/g1.b.png", "b", 24, 0, 16, 16, nil},
+		},
+	}
+
+	write := func(format AtlasFormat) string {
+		buf := bytes.Buffer{}
+		Ω(WriteAtlas(&buf, sheet, format)).Should(Succeed())
+		return buf.String()
+	}
+
+	It("JSON hash", func() {
+		Ω(write(AtlasJSONHash)).Should(MatchJSON(`{
+	"frames": {
+		"g1.a.png": {
+			"frame": {"x": 0, "y": 0, "w": 24, "h": 24},
+			"rotated": false,
+			"trimmed": false,
+			"spriteSourceSize": {"x": 0, "y": 0, "w": 24, "h": 24},
+			"sourceSize": {"w": 24, "h": 24}
+		},
+		"img/g1.b.png": {
+			"frame": {"x": 24, "y": 0, "w": 16, "h": 16},
+			"rotated": false,
+			"trimmed": false,
+			"spriteSourceSize": {"x": 0, "y": 0, "w": 16, "h": 16},
+			"sourceSize": {"w": 16, "h": 16}
+		}
+	},
+	"meta": {
+		"app": "github.com/redforks/css/sprite",
+		"version": "1.0",
+		"image": "g1.png",
+		"format": "RGBA8888",
+		"size": {"w": 40, "h": 24},
+		"scale": "1"
+	}
+}`))
+	})
+
+	It("JSON array", func() {
+		Ω(write(AtlasJSONArray)).Should(MatchJSON(`{
+	"frames": [{
+		"filename": "g1.a.png",
+		"frame": {"x": 0, "y": 0, "w": 24, "h": 24},
+		"rotated": false,
+		"trimmed": false,
+		"spriteSourceSize": {"x": 0, "y": 0, "w": 24, "h": 24},
+		"sourceSize": {"w": 24, "h": 24}
+	}, {
+		"filename": "img/g1.b.png",
+		"frame": {"x": 24, "y": 0, "w": 16, "h": 16},
+		"rotated": false,
+		"trimmed": false,
+		"spriteSourceSize": {"x": 0, "y": 0, "w": 16, "h": 16},
+		"sourceSize": {"w": 16, "h": 16}
+	}],
+	"meta": {
+		"app": "github.com/redforks/css/sprite",
+		"version": "1.0",
+		"image": "g1.png",
+		"format": "RGBA8888",
+		"size": {"w": 40, "h": 24},
+		"scale": "1"
+	}
+}`))
+	})
+
+	It("Sparrow", func() {
+		Ω(write(AtlasSparrow)).Should(MatchXML(`<?xml version="1.0" encoding="UTF-8"?>
+<TextureAtlas imagePath="g1.png">
+	<SubTexture name="g1.a.png" x="0" y="0" width="24" height="24"/>
+	<SubTexture name="img/g1.b.png" x="24" y="0" width="16" height="16"/>
+</TextureAtlas>`))
+	})
+
+	It("Atlas file", func() {
+		Ω(AtlasFile(sheet, AtlasJSONHash)).Should(Equal("g1.json"))
+		Ω(AtlasFile(sheet, AtlasJSONArray)).Should(Equal("g1.array.json"))
+		Ω(AtlasFile(sheet, AtlasSparrow)).Should(Equal("g1.xml"))
+	})
+
+	It("Parse", func() {
+		for _, f := range []AtlasFormat{AtlasJSONHash, AtlasJSONArray, AtlasSparrow} {
+			Ω(ParseAtlasFormat(f.String())).Should(Equal(f))
+		}
+		_, err := ParseAtlasFormat("foo")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Gen writes atlases", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g2.t2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url(g1.t1.png); }
+	.bar { background: url(g2.t2.png); }
+		`, ts)
+		s.AtlasFormats = []AtlasFormat{AtlasJSONHash, AtlasSparrow}
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(ts.files).Should(HaveLen(4))
+		Ω(ts.files["g1.json"].String()).Should(ContainSubstring(`"g1.t1.png"`))
+		Ω(ts.files["g2.xml"].String()).Should(ContainSubstring(`imagePath="g2.png"`))
+	})
+
+})
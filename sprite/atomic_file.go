@@ -0,0 +1,81 @@
+package sprite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultFileMode is the permission of files created by file Service if not
+// specified.
+const DefaultFileMode os.FileMode = 0644
+
+// AtomicFile is a file written to a temporary file in the same directory,
+// renamed to its path on Close(). Readers never see a partially written file,
+// the existing file untouched if writing failed.
+type AtomicFile struct {
+	*os.File
+	path string
+	mode os.FileMode
+	done bool
+}
+
+// CreateAtomic creates AtomicFile at path, the file has permission mode after
+// Close().
+func CreateAtomic(path string, mode os.FileMode) (*AtomicFile, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: f, path: path, mode: mode}, nil
+}
+
+// Close flushes and renames the temporary file to path. Temporary file
+// removed if failed. Calling Close() after Close() or Abort() does nothing.
+func (f *AtomicFile) Close() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	err := f.Sync()
+	if e := f.File.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), f.mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Abort discards written content, removes the temporary file, leaves path
+// untouched.
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// WriteFileAtomic writes data to file at path with permission mode by
+// AtomicFile.
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	f, err := CreateAtomic(path, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Close()
+}
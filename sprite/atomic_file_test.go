@@ -0,0 +1,68 @@
+package sprite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AtomicFile", func() {
+	var dir, file string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "spriter")
+		Ω(err).Should(Succeed())
+		file = filepath.Join(dir, "a.css")
+		Ω(ioutil.WriteFile(file, []byte("old"), 0600)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	// files in dir other than file
+	tempFiles := func() []string {
+		names, err := filepath.Glob(filepath.Join(dir, ".*"))
+		Ω(err).Should(Succeed())
+		return names
+	}
+
+	It("Close", func() {
+		f, err := CreateAtomic(file, 0640)
+		Ω(err).Should(Succeed())
+		f.Write([]byte("new"))
+		Ω(ioutil.ReadFile(file)).Should(Equal([]byte("old")))
+
+		Ω(f.Close()).Should(Succeed())
+		Ω(f.Close()).Should(Succeed())
+		Ω(ioutil.ReadFile(file)).Should(Equal([]byte("new")))
+		info, err := os.Stat(file)
+		Ω(err).Should(Succeed())
+		Ω(info.Mode().Perm()).Should(Equal(os.FileMode(0640)))
+		Ω(tempFiles()).Should(BeEmpty())
+	})
+
+	It("Abort", func() {
+		f, err := CreateAtomic(file, 0640)
+		Ω(err).Should(Succeed())
+		f.Write([]byte("new"))
+		Ω(f.Abort()).Should(Succeed())
+		Ω(f.Close()).Should(Succeed())
+
+		Ω(ioutil.ReadFile(file)).Should(Equal([]byte("old")))
+		Ω(tempFiles()).Should(BeEmpty())
+	})
+
+	It("WriteFileAtomic", func() {
+		Ω(WriteFileAtomic(file, []byte("new"), 0644)).Should(Succeed())
+		Ω(ioutil.ReadFile(file)).Should(Equal([]byte("new")))
+
+		Ω(WriteFileAtomic(filepath.Join(dir, "no", "b.css"), nil, 0644)).ShouldNot(Succeed())
+		Ω(tempFiles()).Should(BeEmpty())
+	})
+
+})
@@ -0,0 +1,113 @@
+package sprite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redforks/errors"
+)
+
+// Budget limits size of a generated sprite, zero field means no limit.
+type Budget struct {
+	// MaxBytes is the max size of encoded sprite image.
+	MaxBytes int64
+
+	MaxWidth, MaxHeight int
+
+	// MaxStamps is the max number of distinct images in sprite.
+	MaxStamps int
+}
+
+// ParseBudget parses Budget from comma separated limits, such as
+// "bytes=102400,width=1024,height=256,stamps=50". Omitted limits are zero.
+func ParseBudget(s string) (Budget, error) {
+	var b Budget
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		i := strings.IndexByte(item, '=')
+		if i == -1 {
+			return Budget{}, errors.Inputf("bad budget limit %s, expect name=value", item)
+		}
+		name, value := item[:i], item[i+1:]
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || v < 0 {
+			return Budget{}, errors.Inputf("bad budget limit %s, value should be a non-negative integer", item)
+		}
+
+		switch name {
+		case "bytes":
+			b.MaxBytes = v
+		case "width":
+			b.MaxWidth = int(v)
+		case "height":
+			b.MaxHeight = int(v)
+		case "stamps":
+			b.MaxStamps = int(v)
+		default:
+			return Budget{}, errors.Inputf("unknown budget limit %s", name)
+		}
+	}
+	return b, nil
+}
+
+// Returns descriptions of limits exceeded by st.
+func (b Budget) check(st *Stats) []string {
+	var exceeded []string
+	add := func(name string, v, max int64) {
+		if max > 0 && v > max {
+			exceeded = append(exceeded, fmt.Sprintf("%s %d > %d", name, v, max))
+		}
+	}
+	add("bytes", st.SpriteBytes, b.MaxBytes)
+	add("width", int64(st.Width), int64(b.MaxWidth))
+	add("height", int64(st.Height), int64(b.MaxHeight))
+	add("stamps", int64(st.Images), int64(b.MaxStamps))
+	return exceeded
+}
+
+// Returns stats of all sprites for TotalBudget: bytes and images summed,
+// width and height of the largest sprite.
+func totalStats(stats []*Stats) *Stats {
+	total := &Stats{}
+	for _, st := range stats {
+		total.SpriteBytes += st.SpriteBytes
+		total.Images += st.Images
+		if st.Width > total.Width {
+			total.Width = st.Width
+		}
+		if st.Height > total.Height {
+			total.Height = st.Height
+		}
+	}
+	return total
+}
+
+// BudgetError is returned by Spriter.Gen() wrapped as errors.ByInput, if a
+// sprite exceeds its Budget, or sprites exceed Spriter.TotalBudget. Sprite
+// image over budget is not saved.
+type BudgetError struct {
+	// Group of the sprite over budget, empty if TotalBudget exceeded.
+	Group string
+
+	// Exceeded limits, such as "bytes 512000 > 102400".
+	Exceeded []string
+}
+
+func (e *BudgetError) Error() string {
+	if e.Group == "" {
+		return fmt.Sprintf("sprites exceed total budget: %s", strings.Join(e.Exceeded, ", "))
+	}
+	return fmt.Sprintf("sprite %s exceeds budget: %s", e.Group, strings.Join(e.Exceeded, ", "))
+}
+
+// Returns budget of sprite of group.
+func (s *Spriter) budget(group string) Budget {
+	if b, ok := s.GroupBudgets[group]; ok {
+		return b
+	}
+	return s.Budget
+}
@@ -0,0 +1,109 @@
+package sprite
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	"github.com/redforks/errors"
+)
+
+var _ = Describe("Budget", func() {
+
+	DescribeTable("ParseBudget", func(s string, exp Budget) {
+		b, err := ParseBudget(s)
+		Ω(err).Should(Succeed())
+		Ω(b).Should(Equal(exp))
+	},
+		Entry("empty", "", Budget{}),
+		Entry("bytes", "bytes=1024", Budget{MaxBytes: 1024}),
+		Entry("all", "bytes=1, width=2,height=3,stamps=4", Budget{1, 2, 3, 4}),
+	)
+
+	DescribeTable("ParseBudget error", func(s string) {
+		_, err := ParseBudget(s)
+		Ω(err).Should(HaveOccurred())
+	},
+		Entry("no value", "bytes"),
+		Entry("not number", "bytes=1k"),
+		Entry("negative", "width=-1"),
+		Entry("unknown", "depth=3"),
+	)
+
+	gen := func(setup func(s *Spriter)) (*testService, error) {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+			"g2.t3.png": "t3.png",
+		})
+		s := New(`
+	.a { background: url(g1.t1.png); }
+	.b { background: url(g1.t2.png); }
+	.c { background: url(g2.t3.png); }
+		`, ts)
+		setup(s)
+		_, _, err := s.Gen()
+		return ts, err
+	}
+
+	It("Within budget", func() {
+		ts, err := gen(func(s *Spriter) {
+			s.Budget = Budget{MaxBytes: 1 << 20, MaxWidth: 32, MaxHeight: 16, MaxStamps: 2}
+		})
+		Ω(err).Should(Succeed())
+		Ω(ts.sprites).Should(HaveLen(2))
+	})
+
+	It("Exceeds budget", func() {
+		ts, err := gen(func(s *Spriter) {
+			s.Budget = Budget{MaxBytes: 10, MaxWidth: 16, MaxStamps: 1}
+		})
+		Ω(errors.GetCausedBy(err)).Should(Equal(errors.ByInput))
+		Ω(err.(*errors.Error).Err).Should(PointTo(MatchAllFields(Fields{
+			"Group":    Equal("g1"),
+			"Exceeded": ConsistOf(HavePrefix("bytes "), "width 32 > 16", "stamps 2 > 1"),
+		})))
+		Ω(err.Error()).Should(ContainSubstring("sprite g1 exceeds budget: bytes "))
+		Ω(ts.sprites).Should(BeEmpty())
+	})
+
+	It("Group budget", func() {
+		ts, err := gen(func(s *Spriter) {
+			s.Budget = Budget{MaxStamps: 1}
+			s.GroupBudgets = map[string]Budget{"g1": {MaxStamps: 2}}
+		})
+		Ω(err).Should(Succeed())
+		Ω(ts.sprites).Should(HaveLen(2))
+
+		_, err = gen(func(s *Spriter) {
+			s.GroupBudgets = map[string]Budget{"g2": {MaxHeight: 8}}
+		})
+		Ω(err.(*errors.Error).Err).Should(Equal(&BudgetError{"g2", []string{"height 16 > 8"}}))
+	})
+
+	It("Total budget", func() {
+		ts, err := gen(func(s *Spriter) {
+			s.Budget = Budget{MaxStamps: 2}
+			s.TotalBudget = Budget{MaxWidth: 32, MaxHeight: 16, MaxStamps: 3}
+		})
+		Ω(err).Should(Succeed())
+		Ω(ts.sprites).Should(HaveLen(2))
+
+		ts, err = gen(func(s *Spriter) {
+			s.Budget = Budget{MaxStamps: 2}
+			s.TotalBudget = Budget{MaxWidth: 16, MaxStamps: 2}
+		})
+		Ω(errors.GetCausedBy(err)).Should(Equal(errors.ByInput))
+		Ω(err.(*errors.Error).Err).Should(Equal(&BudgetError{"", []string{"width 32 > 16"}}))
+		Ω(err.Error()).Should(ContainSubstring("sprites exceed total budget: width 32 > 16"))
+		Ω(ts.sprites).Should(BeEmpty())
+
+		// each sprite within budget, but not all of them
+		ts, err = gen(func(s *Spriter) {
+			s.TotalBudget = Budget{MaxStamps: 2}
+		})
+		Ω(err.(*errors.Error).Err).Should(Equal(&BudgetError{"", []string{"stamps 3 > 2"}}))
+		Ω(ts.sprites).Should(HaveLen(1))
+	})
+
+})
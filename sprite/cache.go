@@ -0,0 +1,174 @@
+package sprite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/redforks/errors"
+)
+
+// cacheVersion is the version of Cache format, cache of other version is
+// discarded by ReadCache().
+const cacheVersion = 1
+
+// Cache records inputs and outputs of generated sprites for incremental
+// build. Set Spriter.Cache to skip decoding, encoding and saving sprites whose
+// images are unchanged since last build, css is rewritten from cached layout.
+// Gen() updates Cache with generated sprites, save it by WriteCache() for the
+// next build.
+type Cache struct {
+	Version int `json:"version"`
+
+	// Options fingerprints settings affecting sprite images, other than
+	// images and Spriter.Order, such as name template and output directory.
+	// ReadCache() discards cache of different options.
+	Options string `json:"options"`
+
+	// Groups are cached sprites keyed by group name.
+	Groups map[string]*CacheEntry `json:"groups"`
+}
+
+// CacheEntry is the cached sprite of a group.
+type CacheEntry struct {
+	// Key is fingerprint of inputs of the sprite: stamp order, path and
+	// content hash of each image.
+	Key    string       `json:"key"`
+	File   string       `json:"file"`
+	Width  int          `json:"width"`
+	Height int          `json:"height"`
+	Stamps []*StampInfo `json:"stamps"` // in layout order
+	Stats  *Stats       `json:"stats"`
+}
+
+// NewCache creates an empty Cache.
+func NewCache(options string) *Cache {
+	return &Cache{
+		Version: cacheVersion,
+		Options: options,
+		Groups:  make(map[string]*CacheEntry),
+	}
+}
+
+// ReadCache reads Cache written by WriteCache() from r. Returns an empty
+// Cache if r is created by other version, or options not match.
+func ReadCache(r io.Reader, options string) (*Cache, error) {
+	c := &Cache{}
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, errors.NewInput(fmt.Errorf("bad cache file: %v", err))
+	}
+	if c.Version != cacheVersion || c.Options != options || c.Groups == nil {
+		return NewCache(options), nil
+	}
+	return c, nil
+}
+
+// WriteCache writes c to w in JSON.
+func WriteCache(w io.Writer, c *Cache) error {
+	buf, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.NewBug(err)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// SpriteChecker is implemented by Service able to tell whether a sprite image
+// exists. If Service implements it, cached sprite deleted since last build is
+// regenerated.
+type SpriteChecker interface {
+	SpriteExists(path string) bool
+}
+
+func contentHash(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// Returns cache key of sprite of sts, "" if Cache not used.
+func (s *Spriter) cacheKey(sts []*stamp) string {
+	if s.Cache == nil {
+		return ""
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", s.Order)
+	for _, st := range sts {
+		fmt.Fprintf(h, "%s\x00%s\n", st.filename, st.hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Load sprite of group from Cache if its key matches, and the sprite image
+// still exists. Stamp positions restored from the cached layout.
+func (s *Spriter) fromCache(group, key string, sts []*stamp) (*spriteImage, bool) {
+	if s.Cache == nil {
+		return nil, false
+	}
+	e := s.Cache.Groups[group]
+	if e == nil || e.Key != key || e.Stats == nil || len(e.Stamps) != len(sts) {
+		return nil, false
+	}
+	if checker, ok := s.sv.(SpriteChecker); ok && !checker.SpriteExists(e.File) {
+		return nil, false
+	}
+
+	byPath := make(map[string]*stamp, len(sts))
+	for _, st := range sts {
+		byPath[st.filename] = st
+	}
+	placed := make([]*stamp, 0, len(sts))
+	for _, info := range e.Stamps {
+		st := byPath[info.Path]
+		if st == nil {
+			return nil, false
+		}
+		placed = append(placed, st)
+	}
+	for i, info := range e.Stamps {
+		placed[i].sp = image.Pt(-info.X, info.Y)
+		placed[i].dims = image.Pt(info.Width, info.Height)
+	}
+
+	stats := *e.Stats
+	stats.DecodeTime, stats.PackTime, stats.EncodeTime = 0, 0, 0
+	stats.Cached = true
+	return &spriteImage{
+		name:   e.File,
+		size:   image.Pt(e.Width, e.Height),
+		stamps: placed,
+		stats:  &stats,
+	}, true
+}
+
+// Record generated sprite of group in Cache.
+func (s *Spriter) cacheSprite(group, key, name string, sp *spriteImage) {
+	if s.Cache == nil {
+		return
+	}
+
+	sheet := newSheet(group, name, "", sp.size, sp.stamps, nil)
+	s.Cache.Groups[group] = &CacheEntry{
+		Key:    key,
+		File:   name,
+		Width:  sp.size.X,
+		Height: sp.size.Y,
+		Stamps: sheet.Stamps,
+		Stats:  sp.stats,
+	}
+}
+
+// Remove groups not generated by current build from Cache.
+func (s *Spriter) pruneCache(groups map[string][]*cssImage) {
+	if s.Cache == nil {
+		return
+	}
+	for g := range s.Cache.Groups {
+		if _, ok := groups[g]; !ok {
+			delete(s.Cache.Groups, g)
+		}
+	}
+}
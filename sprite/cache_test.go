@@ -0,0 +1,158 @@
+package sprite
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	const css = `
+	.a { background: url(g1.t1.png); }
+	.b { background: url(g1.t2.png); }
+	.c { background: url(g2.t3.png) no-repeat; }
+	.d { background: url(other.png); }
+		`
+
+	var (
+		ts    *testService
+		cache *Cache
+	)
+
+	BeforeEach(func() {
+		ts = newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+			"g2.t3.png": "t3.png",
+		})
+		cache = NewCache("")
+	})
+
+	gen := func(css string) (string, *Spriter) {
+		s := New(css, ts)
+		s.Cache = cache
+		out, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		return out, s
+	}
+
+	It("Reuse unchanged sprites", func() {
+		exp, s := gen(css)
+		Ω(cache.Groups).Should(HaveLen(2))
+		Ω(cache.Groups["g1"].Stamps).Should(HaveLen(2))
+		Ω(s.Stats()[0].Cached).Should(BeFalse())
+		sprites := map[string]*bytes.Buffer{}
+		for k, v := range ts.sprites {
+			sprites[k] = v
+		}
+
+		out, s := gen(css)
+		Ω(out).Should(Equal(exp))
+		Ω(ts.sprites).Should(Equal(sprites))
+		for name, buf := range ts.sprites {
+			Ω(buf).Should(BeIdenticalTo(sprites[name]), name)
+		}
+		Ω(s.Stats()[0].Cached).Should(BeTrue())
+		Ω(s.Stats()[0].Images).Should(Equal(2))
+		Ω(s.Sheets()[0].Stamps[1].Selectors).Should(Equal([]string{".b"}))
+	})
+
+	It("Regenerate changed sprite", func() {
+		gen(css)
+		g2 := cache.Groups["g2"].File
+		g2buf := ts.sprites[g2]
+
+		ts.images["g1.t2.png"] = ts.images["g2.t3.png"]
+		out, s := gen(css)
+		Ω(s.Stats()[0].Cached).Should(BeFalse())
+		Ω(s.Stats()[1].Cached).Should(BeTrue())
+		Ω(ts.sprites[g2]).Should(BeIdenticalTo(g2buf))
+		Ω(out).Should(ContainSubstring(cache.Groups["g1"].File))
+	})
+
+	It("Regenerate on order changed", func() {
+		gen(css)
+		key := cache.Groups["g1"].Key
+
+		s := New(css, ts)
+		s.Cache = cache
+		s.Order = OrderName
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(s.Stats()[0].Cached).Should(BeFalse())
+		Ω(cache.Groups["g1"].Key).ShouldNot(Equal(key))
+	})
+
+	It("Regenerate deleted sprite", func() {
+		gen(css)
+		delete(ts.sprites, cache.Groups["g1"].File)
+
+		_, s := gen(css)
+		Ω(s.Stats()[0].Cached).Should(BeFalse())
+		Ω(ts.sprites).Should(HaveKey(cache.Groups["g1"].File))
+	})
+
+	It("Rewrite css with cached layout", func() {
+		gen(css)
+		out, _ := gen(`.x { background: url(g1.t2.png); } .y { background: url(g1.t1.png); }`)
+		Ω(out).Should(Equal(`.x { background: url(` + cache.Groups["g1"].File + `) no-repeat; } .y { background: url(` + cache.Groups["g1"].File + `) no-repeat -16px 0; }`))
+	})
+
+	It("Prune removed groups", func() {
+		gen(css)
+		gen(`.a { background: url(g2.t3.png); }`)
+		Ω(cache.Groups).Should(HaveLen(1))
+		Ω(cache.Groups).Should(HaveKey("g2"))
+	})
+
+	It("Decode error of changed image", func() {
+		gen(css)
+		ts.images["g1.t2.png"] = []byte("not a png")
+		s := New(css, ts)
+		s.Cache = cache
+		_, diags, err := s.Gen()
+		Ω(err).Should(HaveOccurred())
+		Ω(diags).Should(HaveLen(1))
+		Ω(diags[0].Code).Should(Equal(CodeDecodeImage))
+		Ω(diags[0].Line).Should(Equal(3))
+	})
+
+	Context("Read and write", func() {
+
+		It("Round trip", func() {
+			cache.Options = "opts"
+			gen(css)
+			buf := &bytes.Buffer{}
+			Ω(WriteCache(buf, cache)).Should(Succeed())
+
+			c, err := ReadCache(bytes.NewReader(buf.Bytes()), "opts")
+			Ω(err).Should(Succeed())
+			Ω(c).Should(Equal(cache))
+		})
+
+		It("Options changed", func() {
+			cache.Options = "opts"
+			gen(css)
+			buf := &bytes.Buffer{}
+			Ω(WriteCache(buf, cache)).Should(Succeed())
+
+			c, err := ReadCache(bytes.NewReader(buf.Bytes()), "other")
+			Ω(err).Should(Succeed())
+			Ω(c).Should(Equal(NewCache("other")))
+		})
+
+		It("Other version", func() {
+			c, err := ReadCache(bytes.NewBufferString(`{"version": 0, "groups": {}}`), "")
+			Ω(err).Should(Succeed())
+			Ω(c).Should(Equal(NewCache("")))
+		})
+
+		It("Bad file", func() {
+			_, err := ReadCache(bytes.NewBufferString(`{`), "")
+			Ω(err).Should(HaveOccurred())
+		})
+
+	})
+
+})
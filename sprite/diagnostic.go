@@ -0,0 +1,106 @@
+package sprite
+
+import (
+	"fmt"
+
+	"github.com/redforks/css-1/scanner"
+	"github.com/redforks/errors"
+)
+
+// Severity of a Diagnostic.
+type Severity int
+
+const (
+	// Warning reports a problem that Spriter can work around, generation
+	// continues.
+	Warning Severity = iota
+
+	// Error reports a problem that aborts the generation.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic codes, identify the kind of problem reported by a Diagnostic.
+const (
+	// CodeSyntax: css file can not be tokenized.
+	CodeSyntax = "syntax"
+
+	// CodeBadURL: url() can not be parsed, or is empty.
+	CodeBadURL = "bad-url"
+
+	// CodeAbsoluteURL: image referenced by absolute path, can not be resolved
+	// by Service.
+	CodeAbsoluteURL = "absolute-url"
+
+	// CodeRemoteURL: image referenced from other web site.
+	CodeRemoteURL = "remote-url"
+
+	// CodePathEscapes: image path resolves to outside of base paths, refused
+	// by strict file Service.
+	CodePathEscapes = "path-escapes"
+
+	// CodeOpenImage: Service failed to open the image file.
+	CodeOpenImage = "open-image"
+
+	// CodeDecodeImage: image file is not a valid image.
+	CodeDecodeImage = "decode-image"
+)
+
+// Diagnostic describes a warning or error found while generating sprites,
+// located by the position of the css token caused it.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+
+	// Position of the token in css file, both 1 based.
+	Line, Column int
+}
+
+// String returns diagnostic in "line:col: severity: message [code]" format,
+// prefix it with css file name to get the common file:line:col form. Position
+// omitted if Line is 0, such as diagnostics of NewFromImages() Spriter.
+func (d *Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s [%s]", d.Severity, d.Message, d.Code)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s [%s]", d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+// Error implements error interface, an Error Diagnostic is returned by
+// Spriter.Gen() wrapped as errors.ByInput.
+func (d *Diagnostic) Error() string {
+	return d.String()
+}
+
+// Append a Warning diagnostic located at tk.
+func (s *Spriter) warn(tk *scanner.Token, code, format string, a ...interface{}) {
+	s.diags = append(s.diags, newDiagnostic(Warning, tk, code, format, a...))
+}
+
+// Append an Error diagnostic located at tk, returns it as ByInput error.
+func (s *Spriter) fail(tk *scanner.Token, code, format string, a ...interface{}) error {
+	d := newDiagnostic(Error, tk, code, format, a...)
+	s.diags = append(s.diags, d)
+	return errors.NewInput(d)
+}
+
+func newDiagnostic(severity Severity, tk *scanner.Token, code, format string, a ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Line:     tk.Line,
+		Column:   tk.Column,
+	}
+}
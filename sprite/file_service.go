@@ -3,14 +3,24 @@ package sprite
 import (
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/redforks/errors"
 )
 
+// ErrPathEscapes returned by strict file Service if the image path resolves
+// to outside of its base paths, such as "../../etc/g.x.png".
+var ErrPathEscapes = errors.Input("image path escapes base paths")
+
 // FileService implement Service interface to load/save image to/from file
 // system.
 type fileService struct {
 	srcPaths []string
 	outPath  string
+	strict   bool
+	mode     os.FileMode
 }
 
 // Create a Service work with file system.
@@ -19,14 +29,33 @@ type fileService struct {
 //  it is the directory where src .css file is. Can be multiple path, if the
 //  .img not exist in 1st path, search it in next path.
 //  outPath: Base path used to resolve generated sprite image files. Normally
-//  it is the diretory where out .css file is.
+//  it is the diretory where out .css file is, if not, set Spriter.URLPrefix
+//  accordingly.
 func NewFileService(srcPaths []string, outPath string) Service {
-	return &fileService{srcPaths, outPath}
+	return NewFileServiceMode(srcPaths, outPath, false, DefaultFileMode)
 }
 
-func (f *fileService) OpenImage(path string) (r io.Reader, err error) {
+// Create a Service work with file system like NewFileService(), but refuses
+// to open image files outside of srcPaths, OpenImage() returns ErrPathEscapes.
+func NewStrictFileService(srcPaths []string, outPath string) Service {
+	return NewFileServiceMode(srcPaths, outPath, true, DefaultFileMode)
+}
+
+// Create a Service work with file system like NewFileService(), or like
+// NewStrictFileService() if strict is true. Created files have permission
+// mode.
+func NewFileServiceMode(srcPaths []string, outPath string, strict bool, mode os.FileMode) Service {
+	return &fileService{srcPaths, outPath, strict, mode}
+}
+
+func (f *fileService) OpenImage(imgPath string) (r io.Reader, err error) {
+	imgPath = path.Clean(imgPath)
+	if f.strict && escapesBase(imgPath) {
+		return nil, ErrPathEscapes
+	}
+
 	for _, srcPath := range f.srcPaths {
-		var p = filepath.Join(srcPath, path)
+		var p = filepath.Join(srcPath, filepath.FromSlash(imgPath))
 		if r, err = os.Open(p); err != nil {
 			if os.IsNotExist(err) {
 				continue
@@ -38,7 +67,89 @@ func (f *fileService) OpenImage(path string) (r io.Reader, err error) {
 	return
 }
 
+// CreateSpriteImage implements Service interface, sprite is written to an
+// AtomicFile, appears at path after closed.
 func (f *fileService) CreateSpriteImage(path string) (io.Writer, error) {
 	var p = filepath.Join(f.outPath, path)
-	return os.Create(p)
+	w, err := CreateAtomic(p, f.mode)
+	if err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+	return w, nil
+}
+
+// SpriteExists implements SpriteChecker interface.
+func (f *fileService) SpriteExists(path string) bool {
+	_, err := os.Stat(filepath.Join(f.outPath, path))
+	return err == nil
+}
+
+// CreateFile creates AtomicFile at path, relative path resolved against
+// outPath. Parent directories are created if not exist.
+func (f *fileService) CreateFile(path string) (io.Writer, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.outPath, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+
+	w, err := CreateAtomic(path, f.mode)
+	if err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+	return w, nil
+}
+
+// RemoveFile implements FileRemover interface, path resolved against outPath,
+// refuses to remove files outside of outPath.
+func (f *fileService) RemoveFile(p string) error {
+	p = path.Clean(filepath.ToSlash(p))
+	if escapesBase(p) {
+		return errors.Inputf("refuse to remove %s, outside of output directory", p)
+	}
+	err := os.Remove(filepath.Join(f.outPath, filepath.FromSlash(p)))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+// Returns true if cleaned slash separated path p is absolute or goes up
+// beyond its base directory.
+func escapesBase(p string) bool {
+	return path.IsAbs(p) || filepath.IsAbs(filepath.FromSlash(p)) ||
+		p == ".." || strings.HasPrefix(p, "../")
+}
+
+// ListImages implements ImageLister, returns png files in srcPaths and their
+// sub directories. If the same path exist in multiple srcPaths, returned once.
+func (f *fileService) ListImages() ([]string, error) {
+	var images []string
+	found := make(map[string]bool)
+	for _, srcPath := range f.srcPaths {
+		err := filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(p) != ".png" {
+				return nil
+			}
+
+			rel, err := filepath.Rel(srcPath, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if !found[rel] {
+				found[rel] = true
+				images = append(images, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.NewInput(err)
+		}
+	}
+	return images, nil
 }
@@ -0,0 +1,125 @@
+package sprite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("fileService", func() {
+	var dir, base string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "spriter")
+		Ω(err).Should(Succeed())
+
+		base = filepath.Join(dir, "css")
+		Ω(os.MkdirAll(filepath.Join(base, "img"), 0755)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(base, "img", "g1.t1.png"), MustAsset("testdata/t1.png"), 0644)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(dir, "g1.t2.png"), MustAsset("testdata/t2.png"), 0644)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	It("Open image", func() {
+		sv := NewFileService([]string{dir, base}, dir)
+		r, err := sv.OpenImage("img/../img/g1.t1.png")
+		Ω(err).Should(Succeed())
+		closeClosable(r)
+	})
+
+	It("Not exist", func() {
+		sv := NewFileService([]string{base}, dir)
+		_, err := sv.OpenImage("img/g1.t3.png")
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("Open image outside of base", func() {
+		sv := NewFileService([]string{base}, dir)
+		r, err := sv.OpenImage("../g1.t2.png")
+		Ω(err).Should(Succeed())
+		closeClosable(r)
+	})
+
+	It("List images", func() {
+		Ω(ioutil.WriteFile(filepath.Join(base, "foo.txt"), nil, 0644)).Should(Succeed())
+		sv := NewFileService([]string{base, dir, base}, dir)
+		Ω(sv.(ImageLister).ListImages()).Should(Equal([]string{
+			"img/g1.t1.png",
+			"css/img/g1.t1.png",
+			"g1.t2.png",
+		}))
+	})
+
+	It("Sprite exists", func() {
+		sv := NewFileService([]string{base}, dir).(SpriteChecker)
+		Ω(sv.SpriteExists("a.png")).Should(BeFalse())
+		Ω(ioutil.WriteFile(filepath.Join(dir, "a.png"), nil, 0644)).Should(Succeed())
+		Ω(sv.SpriteExists("a.png")).Should(BeTrue())
+	})
+
+	It("Create file", func() {
+		sv := NewFileService([]string{base}, dir).(FileCreator)
+		for _, p := range []string{"a.json", "sub/b.json", filepath.Join(dir, "c", "c.json")} {
+			w, err := sv.CreateFile(p)
+			Ω(err).Should(Succeed())
+			closeClosable(w)
+		}
+		Ω(filepath.Join(dir, "a.json")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, "sub", "b.json")).Should(BeAnExistingFile())
+		Ω(filepath.Join(dir, "c", "c.json")).Should(BeAnExistingFile())
+	})
+
+	It("Sprite written atomically with mode", func() {
+		sv := NewFileServiceMode([]string{base}, dir, false, 0600)
+		w, err := sv.CreateSpriteImage("a.png")
+		Ω(err).Should(Succeed())
+		w.Write([]byte("sprite"))
+		Ω(filepath.Join(dir, "a.png")).ShouldNot(BeAnExistingFile())
+
+		closeClosable(w)
+		info, err := os.Stat(filepath.Join(dir, "a.png"))
+		Ω(err).Should(Succeed())
+		Ω(info.Mode().Perm()).Should(Equal(os.FileMode(0600)))
+	})
+
+	It("Remove file", func() {
+		sv := NewFileService([]string{base}, dir).(FileRemover)
+		Ω(ioutil.WriteFile(filepath.Join(dir, "a.png"), nil, 0644)).Should(Succeed())
+		Ω(sv.RemoveFile("a.png")).Should(Succeed())
+		Ω(filepath.Join(dir, "a.png")).ShouldNot(BeAnExistingFile())
+		Ω(sv.RemoveFile("a.png")).Should(Succeed())
+
+		Ω(sv.RemoveFile("../g1.t2.png")).ShouldNot(Succeed())
+		Ω(sv.RemoveFile("css/../../g1.t2.png")).ShouldNot(Succeed())
+	})
+
+	It("Strict", func() {
+		sv := NewStrictFileService([]string{base}, dir)
+		r, err := sv.OpenImage("img/g1.t1.png")
+		Ω(err).Should(Succeed())
+		closeClosable(r)
+
+		for _, p := range []string{"../g1.t2.png", "img/../../g1.t2.png", "/etc/g1.t2.png", ".."} {
+			_, err = sv.OpenImage(p)
+			Ω(err).Should(Equal(ErrPathEscapes), p)
+		}
+	})
+
+	It("Strict reported as diagnostic", func() {
+		s := New(`
+	.foo { background: url(img/g1.t1.png); }
+	.bar { background: url(../g1.t2.png); }`, NewStrictFileService([]string{base}, dir))
+		_, diags, err := s.Gen()
+		Ω(err).Should(HaveOccurred())
+		Ω(diags).Should(HaveLen(1))
+		Ω(diags[0].String()).Should(Equal("3:21: error: image ../g1.t2.png is outside of base paths [path-escapes]"))
+	})
+
+})
@@ -0,0 +1,152 @@
+package sprite
+
+import (
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/redforks/errors"
+)
+
+// OutputService is the output half of Service, creates generated files.
+type OutputService interface {
+	// Create sprite image file return as io.Writer. Spriter will close the
+	// io.Writer if it also implements io.Closer. path is the sprite file name,
+	// referenced in css as Spriter.URLPrefix + path.
+	CreateSpriteImage(path string) (io.Writer, error)
+}
+
+// FileCreator is implemented by OutputService able to create generated files
+// other than sprite images, needed by Spriter.ManifestFile, SCSSFile,
+// LessFile, ReportFile and AtlasFormats.
+type FileCreator interface {
+	// CreateFile creates other generated file, such as manifest, return as
+	// io.Writer. Spriter will close the io.Writer if it also implements
+	// io.Closer.
+	CreateFile(path string) (io.Writer, error)
+}
+
+// Returns FileCreator of sv, nil if not implemented. Service created by
+// NewFSService() or WithOutput() implements it if its OutputService does.
+func fileCreator(sv Service) FileCreator {
+	var c FileCreator
+	switch v := sv.(type) {
+	case *fsService:
+		c, _ = v.OutputService.(FileCreator)
+	case *outputService:
+		c, _ = v.out.(FileCreator)
+	default:
+		c, _ = sv.(FileCreator)
+	}
+	return c
+}
+
+type fsService struct {
+	OutputService
+	fsys fs.FS
+	dir  string
+}
+
+// NewFSService creates Service reads images from fsys, such as embed.FS, and
+// creates generated files by out, such as a MemoryService or a file Service.
+// Image paths are resolved from root of fsys, path outside of fsys refused
+// with ErrPathEscapes.
+func NewFSService(fsys fs.FS, out OutputService) Service {
+	return NewFSServiceDir(fsys, ".", out)
+}
+
+// NewFSServiceDir creates Service like NewFSService(), but image paths are
+// resolved relative to dir inside fsys, such as the directory of css file.
+// Images outside of dir are fine, such as "../img/g1.a.png", as long as they
+// are inside fsys.
+func NewFSServiceDir(fsys fs.FS, dir string, out OutputService) Service {
+	return &fsService{out, fsys, path.Clean(dir)}
+}
+
+func (f *fsService) OpenImage(p string) (io.Reader, error) {
+	if path.IsAbs(p) {
+		return nil, ErrPathEscapes
+	}
+	p = path.Join(f.dir, p)
+	if !fs.ValidPath(p) {
+		return nil, ErrPathEscapes
+	}
+	return f.fsys.Open(p)
+}
+
+// ListImages implements ImageLister, returns png files in dir of fsys,
+// relative to dir.
+func (f *fsService) ListImages() ([]string, error) {
+	var images []string
+	err := fs.WalkDir(f.fsys, f.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && path.Ext(p) == ".png" {
+			if f.dir != "." {
+				p = p[len(f.dir)+1:]
+			}
+			images = append(images, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewInput(err)
+	}
+	return images, nil
+}
+
+// CreateFile implements FileCreator if out implements it.
+func (f *fsService) CreateFile(path string) (io.Writer, error) {
+	if c := fileCreator(f); c != nil {
+		return c.CreateFile(path)
+	}
+	return nil, errors.Bug("OutputService not implement FileCreator")
+}
+
+// SpriteExists implements SpriteChecker, true if out can not tell.
+func (f *fsService) SpriteExists(path string) bool {
+	if checker, ok := f.OutputService.(SpriteChecker); ok {
+		return checker.SpriteExists(path)
+	}
+	return true
+}
+
+type outputService struct {
+	Service
+	out OutputService
+}
+
+// WithOutput returns Service reads images by sv, and creates generated files
+// by out.
+func WithOutput(sv Service, out OutputService) Service {
+	return &outputService{sv, out}
+}
+
+func (o *outputService) CreateSpriteImage(path string) (io.Writer, error) {
+	return o.out.CreateSpriteImage(path)
+}
+
+// CreateFile implements FileCreator if out implements it.
+func (o *outputService) CreateFile(path string) (io.Writer, error) {
+	if c := fileCreator(o); c != nil {
+		return c.CreateFile(path)
+	}
+	return nil, errors.Bug("OutputService not implement FileCreator")
+}
+
+// ListImages implements ImageLister if sv implements it.
+func (o *outputService) ListImages() ([]string, error) {
+	if lister, ok := o.Service.(ImageLister); ok {
+		return lister.ListImages()
+	}
+	return nil, errors.Bug("Service not implement ImageLister")
+}
+
+// SpriteExists implements SpriteChecker, true if out can not tell.
+func (o *outputService) SpriteExists(path string) bool {
+	if checker, ok := o.out.(SpriteChecker); ok {
+		return checker.SpriteExists(path)
+	}
+	return true
+}
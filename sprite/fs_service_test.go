@@ -0,0 +1,83 @@
+package sprite
+
+import (
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("fsService", func() {
+	var (
+		fsys fstest.MapFS
+		out  *MemoryService
+		sv   Service
+	)
+
+	BeforeEach(func() {
+		fsys = fstest.MapFS{
+			"css/img/g1.t1.png": {Data: MustAsset("testdata/t1.png")},
+			"css/g1.t2.png":     {Data: MustAsset("testdata/t2.png")},
+			"css/a.css":         {Data: []byte(".a {}")},
+		}
+		out = NewMemoryService(nil)
+		sv = NewFSService(fsys, out)
+	})
+
+	It("Open image", func() {
+		_, err := sv.OpenImage("css/img/../g1.t2.png")
+		Ω(err).Should(Succeed())
+
+		_, err = sv.OpenImage("css/g1.t3.png")
+		Ω(err).Should(HaveOccurred())
+
+		_, err = sv.OpenImage("../g1.t2.png")
+		Ω(err).Should(Equal(ErrPathEscapes))
+	})
+
+	It("List images", func() {
+		images, err := sv.(ImageLister).ListImages()
+		Ω(err).Should(Succeed())
+		Ω(images).Should(Equal([]string{"css/g1.t2.png", "css/img/g1.t1.png"}))
+	})
+
+	It("Dir", func() {
+		sv = NewFSServiceDir(fsys, "css/img", out)
+		_, err := sv.OpenImage("../g1.t2.png")
+		Ω(err).Should(Succeed())
+
+		_, err = sv.OpenImage("../../../g1.t2.png")
+		Ω(err).Should(Equal(ErrPathEscapes))
+		_, err = sv.OpenImage("/css/g1.t2.png")
+		Ω(err).Should(Equal(ErrPathEscapes))
+
+		Ω(sv.(ImageLister).ListImages()).Should(Equal([]string{"g1.t1.png"}))
+	})
+
+	It("Gen", func() {
+		s := New(`
+	.a { background: url(css/img/g1.t1.png); }
+	.b { background: url(css/g1.t2.png); }`, sv)
+		s.ManifestFile = "sprites.json"
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(out.Sprites()).Should(HaveLen(1))
+		Ω(out.File("sprites.json")).ShouldNot(BeEmpty())
+		Ω(sv.(SpriteChecker).SpriteExists(s.Sheets()[0].File)).Should(BeTrue())
+	})
+
+	It("Output not FileCreator", func() {
+		// only CreateSpriteImage() exposed
+		sv = NewFSService(fsys, struct{ OutputService }{out})
+		s := New(`.a { background: url(css/img/g1.t1.png); }`, sv)
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(out.Sprites()).Should(HaveLen(1))
+
+		s.ManifestFile = "sprites.json"
+		_, _, err = s.Gen()
+		Ω(err).Should(MatchError(ContainSubstring("not implement FileCreator")))
+		Ω(out.Files()).Should(BeEmpty())
+	})
+
+})
@@ -0,0 +1,100 @@
+package sprite
+
+import (
+	"encoding/json"
+	"image"
+
+	"github.com/redforks/errors"
+)
+
+// Sheet describes a generated sprite image.
+type Sheet struct {
+	Group  string       `json:"group"`
+	File   string       `json:"file"` // file name passed to Service.CreateSpriteImage()
+	URL    string       `json:"url"`  // url referenced in css
+	Width  int          `json:"width"`
+	Height int          `json:"height"`
+	Stamps []*StampInfo `json:"stamps"`
+
+	// BaseSelector of the shared base class carrying sprite url, only
+	// Spriter created by NewFromImages() has it.
+	BaseSelector string `json:"baseSelector,omitempty"`
+
+	content []byte // encoded sprite image
+}
+
+// StampInfo describes an image inside sprite.
+type StampInfo struct {
+	// Path of source image, the canonical form of the path referenced in css.
+	Path string `json:"path"`
+	// Name of the image, [name] part of [group].[name].png.
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Selectors of css rules referencing the image.
+	Selectors []string `json:"selectors,omitempty"`
+}
+
+// Manifest is the content of Spriter.ManifestFile.
+type Manifest struct {
+	Sprites []*Sheet `json:"sprites"`
+}
+
+// Create Sheet of sprite image, sts are stamps in layout order, imgs are css
+// references of the stamps.
+func newSheet(group, file, url string, size image.Point, sts []*stamp, imgs []*cssImage) *Sheet {
+	sheet := &Sheet{
+		Group:  group,
+		File:   file,
+		URL:    url,
+		Width:  size.X,
+		Height: size.Y,
+	}
+	infos := make(map[*stamp]*StampInfo)
+	for _, st := range sts {
+		info := &StampInfo{
+			Path:   st.filename,
+			Name:   extractName(st.filename),
+			X:      -st.sp.X,
+			Y:      st.sp.Y,
+			Width:  st.dx(),
+			Height: st.dy(),
+		}
+		infos[st] = info
+		sheet.Stamps = append(sheet.Stamps, info)
+	}
+
+	for _, img := range imgs {
+		info := infos[img.img]
+		if img.selector != "" && !containsString(info.Selectors, img.selector) {
+			info.Selectors = append(info.Selectors, img.selector)
+		}
+	}
+	return sheet
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Spriter) writeManifest() (err error) {
+	buf, err := json.MarshalIndent(&Manifest{s.sheets}, "", "  ")
+	if err != nil {
+		return errors.NewBug(err)
+	}
+
+	f, err := s.createFile(s.ManifestFile)
+	if err != nil {
+		return err
+	}
+	defer closeWriter(f, &err)
+	_, err = f.Write(buf)
+	return err
+}
@@ -0,0 +1,65 @@
+package sprite
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+
+	It("Write manifest", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png":       "24.png",
+			"image/g1.t2.png": "t2.png",
+			"g2.t1.png":       "t1.png",
+		})
+		s := New(`
+	.foo { background: url(g2.t1.png); }
+	.bar { background: url(g1.t1.png); }
+	.foobar { background: url(./image/g1.t2.png); }
+	.foo-bar { background: url(image/g1.t2.png); }
+	.foobar { background: url(image/g1.t2.png); }
+		`, ts)
+		s.ManifestFile = "sprites.json"
+		s.URLPrefix = "/img"
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(ts.files["sprites.json"].String()).Should(MatchJSON(`{
+	"sprites": [{
+		"group": "g1",
+		"file": "g1.png",
+		"url": "/img/g1.png",
+		"width": 40,
+		"height": 24,
+		"stamps": [
+			{"path": "g1.t1.png", "name": "t1", "x": 0, "y": 0, "width": 24, "height": 24, "selectors": [".bar"]},
+			{"path": "image/g1.t2.png", "name": "t2", "x": 24, "y": 0, "width": 16, "height": 16, "selectors": [".foobar", ".foo-bar"]}
+		]
+	}, {
+		"group": "g2",
+		"file": "g2.png",
+		"url": "/img/g2.png",
+		"width": 16,
+		"height": 16,
+		"stamps": [
+			{"path": "g2.t1.png", "name": "t1", "x": 0, "y": 0, "width": 16, "height": 16, "selectors": [".foo"]}
+		]
+	}]
+}`))
+		Ω(s.Sheets()).Should(HaveLen(2))
+	})
+
+	It("No manifest by default", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		s := New(`.foo { background: url(g1.t1.png); }`, ts)
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(ts.files).Should(BeEmpty())
+	})
+
+})
@@ -0,0 +1,116 @@
+package sprite
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// MemoryService is a Service keeps image files and generated files in
+// memory, useful for testing and serving sprites without touching file
+// system. Not safe for concurrent use.
+type MemoryService struct {
+	images  map[string][]byte        // source images path -> content
+	sprites map[string]*bytes.Buffer // created sprites
+	files   map[string]*bytes.Buffer // other created files
+}
+
+// NewMemoryService creates MemoryService serving images, keyed by slash
+// separated path referenced in css, such as "img/g1.a.png". images can be
+// nil.
+func NewMemoryService(images map[string][]byte) *MemoryService {
+	if images == nil {
+		images = make(map[string][]byte)
+	}
+	return &MemoryService{
+		images:  images,
+		sprites: make(map[string]*bytes.Buffer),
+		files:   make(map[string]*bytes.Buffer),
+	}
+}
+
+// OpenImage implements Service interface.
+func (s *MemoryService) OpenImage(p string) (io.Reader, error) {
+	content, ok := s.images[path.Clean(p)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	return bytes.NewReader(content), nil
+}
+
+// CreateSpriteImage implements Service interface.
+func (s *MemoryService) CreateSpriteImage(path string) (io.Writer, error) {
+	buf := &bytes.Buffer{}
+	s.sprites[path] = buf
+	return buf, nil
+}
+
+// CreateFile implements FileCreator interface.
+func (s *MemoryService) CreateFile(path string) (io.Writer, error) {
+	buf := &bytes.Buffer{}
+	s.files[path] = buf
+	return buf, nil
+}
+
+// RemoveFile implements FileRemover interface, removes created sprite image
+// or file.
+func (s *MemoryService) RemoveFile(path string) error {
+	delete(s.sprites, path)
+	delete(s.files, path)
+	return nil
+}
+
+// ListImages implements ImageLister, returns paths of png images, sorted.
+func (s *MemoryService) ListImages() ([]string, error) {
+	var images []string
+	for p := range s.images {
+		if path.Ext(p) == ".png" {
+			images = append(images, p)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// SpriteExists implements SpriteChecker interface.
+func (s *MemoryService) SpriteExists(path string) bool {
+	_, ok := s.sprites[path]
+	return ok
+}
+
+// Sprite returns content of created sprite image, nil if not exist.
+func (s *MemoryService) Sprite(path string) []byte {
+	return bufBytes(s.sprites[path])
+}
+
+// Sprites returns all created sprite images keyed by path.
+func (s *MemoryService) Sprites() map[string][]byte {
+	return bufsBytes(s.sprites)
+}
+
+// File returns content of file created by CreateFile(), nil if not exist.
+func (s *MemoryService) File(path string) []byte {
+	return bufBytes(s.files[path])
+}
+
+// Files returns all files created by CreateFile() keyed by path.
+func (s *MemoryService) Files() map[string][]byte {
+	return bufsBytes(s.files)
+}
+
+func bufBytes(buf *bytes.Buffer) []byte {
+	if buf == nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func bufsBytes(bufs map[string]*bytes.Buffer) map[string][]byte {
+	r := make(map[string][]byte, len(bufs))
+	for p, buf := range bufs {
+		r[p] = buf.Bytes()
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package sprite
+
+import (
+	"io/fs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MemoryService", func() {
+
+	It("Open image", func() {
+		sv := NewMemoryService(map[string][]byte{"img/g1.a.png": []byte("a")})
+		_, err := sv.OpenImage("img/./g1.a.png")
+		Ω(err).Should(Succeed())
+
+		_, err = sv.OpenImage("g1.b.png")
+		Ω(err).Should(MatchError(fs.ErrNotExist))
+	})
+
+	It("Created files", func() {
+		sv := NewMemoryService(nil)
+		w, err := sv.CreateSpriteImage("a.png")
+		Ω(err).Should(Succeed())
+		w.Write([]byte("sprite"))
+		w, err = sv.CreateFile("a.json")
+		Ω(err).Should(Succeed())
+		w.Write([]byte("{}"))
+
+		Ω(sv.Sprite("a.png")).Should(Equal([]byte("sprite")))
+		Ω(sv.Sprite("b.png")).Should(BeNil())
+		Ω(sv.Sprites()).Should(Equal(map[string][]byte{"a.png": []byte("sprite")}))
+		Ω(sv.SpriteExists("a.png")).Should(BeTrue())
+		Ω(sv.SpriteExists("a.json")).Should(BeFalse())
+		Ω(sv.File("a.json")).Should(Equal([]byte("{}")))
+		Ω(sv.Files()).Should(HaveLen(1))
+	})
+
+	It("Gen", func() {
+		sv := NewMemoryService(map[string][]byte{
+			"g1.t1.png": MustAsset("testdata/t1.png"),
+			"g1.t2.png": MustAsset("testdata/t2.png"),
+			"readme.md": []byte("not image"),
+		})
+		images, err := sv.ListImages()
+		Ω(err).Should(Succeed())
+		Ω(images).Should(Equal([]string{"g1.t1.png", "g1.t2.png"}))
+
+		s, err := NewFromImages(sv, DefaultSelector, DefaultBaseSelector)
+		Ω(err).Should(Succeed())
+		_, _, err = s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(sv.Sprite(s.Sheets()[0].File)).ShouldNot(BeEmpty())
+	})
+
+})
@@ -0,0 +1,158 @@
+package sprite
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/redforks/errors"
+)
+
+// Namer decides file name of sprite images.
+type Namer interface {
+	// Name returns file name of the sprite image of group, content is the
+	// encoded image file.
+	Name(group string, content []byte) string
+}
+
+// NamerFunc adapts a function to Namer interface.
+type NamerFunc func(group string, content []byte) string
+
+// Name calls f(group, content).
+func (f NamerFunc) Name(group string, content []byte) string {
+	return f(group, content)
+}
+
+// DefaultNameTemplate is the name template used if Spriter.Namer is nil,
+// with md5 hash algorithm and base64 encoding.
+const DefaultNameTemplate = "[hash:8].[ext]"
+
+var defaultNamer = mustTemplateNamer(DefaultNameTemplate, "md5", "base64")
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+var hashEncodings = map[string]func([]byte) string{
+	"hex":    hex.EncodeToString,
+	"base64": base64.RawURLEncoding.EncodeToString,
+}
+
+type templateNamer struct {
+	parts    []func(group, hash string) string
+	newHash  func() hash.Hash
+	encode   func([]byte) string
+	needHash bool
+}
+
+// NewTemplateNamer creates a Namer generates file name from template, such as
+// "[group]-[hash:10].[ext]". Placeholders:
+//
+//  [group]: group name of the sprite.
+//  [hash]: hash of sprite image content.
+//  [hash:N]: first N characters of the hash.
+//  [ext]: file extension without dot, "png".
+//
+// algorithm is one of "md5", "sha1", "sha256"; encoding of the hash is "hex"
+// or "base64" (url safe, without padding).
+func NewTemplateNamer(template, algorithm, encoding string) (Namer, error) {
+	n := &templateNamer{
+		newHash: hashAlgorithms[algorithm],
+		encode:  hashEncodings[encoding],
+	}
+	if n.newHash == nil {
+		return nil, errors.Inputf("unknown hash algorithm %s", algorithm)
+	}
+	if n.encode == nil {
+		return nil, errors.Inputf("unknown hash encoding %s", encoding)
+	}
+
+	for s := template; s != ""; {
+		start := strings.IndexByte(s, '[')
+		if start == -1 {
+			n.appendLiteral(s)
+			break
+		}
+		n.appendLiteral(s[:start])
+
+		end := strings.IndexByte(s[start:], ']')
+		if end == -1 {
+			return nil, errors.Inputf("unclosed placeholder in name template %s", template)
+		}
+		end += start
+		if err := n.appendPlaceholder(s[start+1 : end]); err != nil {
+			return nil, errors.Inputf("%v in name template %s", err, template)
+		}
+		s = s[end+1:]
+	}
+	return n, nil
+}
+
+func mustTemplateNamer(template, algorithm, encoding string) Namer {
+	n, err := NewTemplateNamer(template, algorithm, encoding)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (n *templateNamer) appendLiteral(s string) {
+	if s != "" {
+		n.parts = append(n.parts, func(string, string) string {
+			return s
+		})
+	}
+}
+
+func (n *templateNamer) appendPlaceholder(name string) error {
+	switch {
+	case name == "group":
+		n.parts = append(n.parts, func(group, _ string) string {
+			return group
+		})
+	case name == "ext":
+		n.appendLiteral("png")
+	case name == "hash":
+		n.needHash = true
+		n.parts = append(n.parts, func(_, hash string) string {
+			return hash
+		})
+	case strings.HasPrefix(name, "hash:"):
+		l, err := strconv.Atoi(name[len("hash:"):])
+		if err != nil || l <= 0 {
+			return errors.Inputf("bad hash length [%s]", name)
+		}
+		n.needHash = true
+		n.parts = append(n.parts, func(_, hash string) string {
+			if len(hash) > l {
+				return hash[:l]
+			}
+			return hash
+		})
+	default:
+		return errors.Inputf("unknown placeholder [%s]", name)
+	}
+	return nil
+}
+
+func (n *templateNamer) Name(group string, content []byte) string {
+	var hashStr string
+	if n.needHash {
+		h := n.newHash()
+		h.Write(content)
+		hashStr = n.encode(h.Sum(nil))
+	}
+
+	buf := strings.Builder{}
+	for _, part := range n.parts {
+		buf.WriteString(part(group, hashStr))
+	}
+	return buf.String()
+}
@@ -0,0 +1,59 @@
+package sprite
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Namer", func() {
+	content := []byte("foo")
+
+	DescribeTable("NewTemplateNamer", func(template, algorithm, encoding, exp string) {
+		n, err := NewTemplateNamer(template, algorithm, encoding)
+		Ω(err).Should(Succeed())
+		Ω(n.Name("grp1", content)).Should(Equal(exp))
+	},
+		Entry("default", DefaultNameTemplate, "md5", "base64", "rL0Y20zC.png"),
+		Entry("group", "[group]-[hash:10].[ext]", "md5", "hex", "grp1-acbd18db4c.png"),
+		Entry("full hash", "[hash].[ext]", "md5", "hex", "acbd18db4cc2f85cedef654fccc4a4d8.png"),
+		Entry("sha1", "[hash:8].[ext]", "sha1", "hex", "0beec7b5.png"),
+		Entry("sha256", "[hash:8].[ext]", "sha256", "hex", "2c26b46b.png"),
+		Entry("length exceeds", "[hash:100]", "md5", "base64", "rL0Y20zC-Fzt72VPzMSk2A"),
+		Entry("no placeholder", "sprite.png", "md5", "hex", "sprite.png"),
+		Entry("group only", "[group].[ext]", "md5", "hex", "grp1.png"),
+	)
+
+	DescribeTable("NewTemplateNamer error", func(template, algorithm, encoding string) {
+		_, err := NewTemplateNamer(template, algorithm, encoding)
+		Ω(err).Should(HaveOccurred())
+	},
+		Entry("unknown algorithm", DefaultNameTemplate, "crc32", "hex"),
+		Entry("unknown encoding", DefaultNameTemplate, "md5", "base32"),
+		Entry("unknown placeholder", "[name].[ext]", "md5", "hex"),
+		Entry("unclosed placeholder", "[hash.[ext]", "md5", "hex"),
+		Entry("bad length", "[hash:x].[ext]", "md5", "hex"),
+		Entry("zero length", "[hash:0].[ext]", "md5", "hex"),
+	)
+
+	It("Spriter uses Namer", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g2.t2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url(g1.t1.png); }
+	.bar { background: url(g2.t2.png); }
+		`, ts)
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(g1.png) no-repeat; }
+	.bar { background: url(g2.png) no-repeat; }
+		`))
+		ts.assertSprite("g1.png", 16, 16)
+		ts.assertSprite("g2.png", 16, 16)
+	})
+
+})
@@ -0,0 +1,62 @@
+package sprite
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/redforks/errors"
+)
+
+// StampOrder decides the order of stamps inside a sprite.
+type StampOrder int
+
+const (
+	// OrderCSS places stamps in the order they first referenced in css.
+	OrderCSS StampOrder = iota
+
+	// OrderName sorts stamps by image path.
+	OrderName
+
+	// OrderSize sorts stamps by height, then width, both descending, ties
+	// broken by image path.
+	OrderSize
+)
+
+var stampOrderNames = []string{"css", "name", "size"}
+
+func (o StampOrder) String() string {
+	if o < 0 || int(o) >= len(stampOrderNames) {
+		return fmt.Sprintf("StampOrder(%d)", int(o))
+	}
+	return stampOrderNames[o]
+}
+
+// ParseStampOrder parses StampOrder from its name: "css", "name" or "size".
+func ParseStampOrder(name string) (StampOrder, error) {
+	for i, n := range stampOrderNames {
+		if n == name {
+			return StampOrder(i), nil
+		}
+	}
+	return OrderCSS, errors.Inputf("unknown stamp order %s", name)
+}
+
+func sortStamps(sts []*stamp, order StampOrder) {
+	switch order {
+	case OrderName:
+		sort.SliceStable(sts, func(i, j int) bool {
+			return sts[i].filename < sts[j].filename
+		})
+	case OrderSize:
+		sort.SliceStable(sts, func(i, j int) bool {
+			a, b := sts[i], sts[j]
+			if a.dy() != b.dy() {
+				return a.dy() > b.dy()
+			}
+			if a.dx() != b.dx() {
+				return a.dx() > b.dx()
+			}
+			return a.filename < b.filename
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package sprite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteSCSS writes SCSS partial of sheets to w. For each sprite group, such
+// as "grp", it defines:
+//
+//  $grp-sprite-url: sprite image url.
+//  $grp-[name]-x, $grp-[name]-y, $grp-[name]-width, $grp-[name]-height:
+//  position and size of each image.
+//  $grp-icons: map of image name to (x, y, width, height) map.
+//  @mixin grp-icon($name): applies background, width and height of an image.
+func WriteSCSS(w io.Writer, sheets []*Sheet) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Generated by spriter, DO NOT EDIT!\n")
+	for _, sheet := range sheets {
+		g := cssIdent(sheet.Group)
+		fmt.Fprintf(buf, "\n$%s-sprite-url: %s;\n", g, strconv.Quote(sheet.URL))
+		for _, st := range sheet.Stamps {
+			n := g + "-" + cssIdent(st.Name)
+			fmt.Fprintf(buf, "$%s-x: %dpx;\n", n, st.X)
+			fmt.Fprintf(buf, "$%s-y: %dpx;\n", n, st.Y)
+			fmt.Fprintf(buf, "$%s-width: %dpx;\n", n, st.Width)
+			fmt.Fprintf(buf, "$%s-height: %dpx;\n", n, st.Height)
+		}
+
+		fmt.Fprintf(buf, "$%s-icons: (\n", g)
+		for _, st := range sheet.Stamps {
+			n := g + "-" + cssIdent(st.Name)
+			fmt.Fprintf(buf, "  %s: (x: $%s-x, y: $%s-y, width: $%s-width, height: $%s-height),\n",
+				cssIdent(st.Name), n, n, n, n)
+		}
+		buf.WriteString(");\n")
+
+		fmt.Fprintf(buf, `@mixin %[1]s-icon($name) {
+  $icon: map-get($%[1]s-icons, $name);
+  background: url($%[1]s-sprite-url) no-repeat (-1 * map-get($icon, x)) (-1 * map-get($icon, y));
+  width: map-get($icon, width);
+  height: map-get($icon, height);
+}
+`, g)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteLess writes Less file of sheets to w. For each sprite group, such as
+// "grp", it defines:
+//
+//  @grp-sprite-url: sprite image url.
+//  @grp-[name]-x, @grp-[name]-y, @grp-[name]-width, @grp-[name]-height:
+//  position and size of each image.
+//  .grp-icon(@name): mixin applies background, width and height of an image.
+func WriteLess(w io.Writer, sheets []*Sheet) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Generated by spriter, DO NOT EDIT!\n")
+	for _, sheet := range sheets {
+		g := cssIdent(sheet.Group)
+		fmt.Fprintf(buf, "\n@%s-sprite-url: %s;\n", g, strconv.Quote(sheet.URL))
+		for _, st := range sheet.Stamps {
+			n := g + "-" + cssIdent(st.Name)
+			fmt.Fprintf(buf, "@%s-x: %dpx;\n", n, st.X)
+			fmt.Fprintf(buf, "@%s-y: %dpx;\n", n, st.Y)
+			fmt.Fprintf(buf, "@%s-width: %dpx;\n", n, st.Width)
+			fmt.Fprintf(buf, "@%s-height: %dpx;\n", n, st.Height)
+		}
+
+		fmt.Fprintf(buf, `.%[1]s-icon(@name) {
+  @x: "%[1]s-@{name}-x";
+  @y: "%[1]s-@{name}-y";
+  @width: "%[1]s-@{name}-width";
+  @height: "%[1]s-@{name}-height";
+  background: url(@%[1]s-sprite-url) no-repeat (-1 * @@x) (-1 * @@y);
+  width: @@width;
+  height: @@height;
+}
+`, g)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Replace characters not allowed in css identifier with '-'.
+func cssIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_', r >= 0x80:
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+func (s *Spriter) writePreprocessorFile(path string, write func(io.Writer, []*Sheet) error) (err error) {
+	f, err := s.createFile(path)
+	if err != nil {
+		return err
+	}
+	defer closeWriter(f, &err)
+	return write(f, s.sheets)
+}
@@ -0,0 +1,88 @@
+package sprite
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Preprocessor", func() {
+	sheets := []*Sheet{{
+		Group:  "g1",
+		File:   "g1.png",
+		URL:    "../img/g1.png",
+		Width:  40,
+		Height: 24,
+		Stamps: []*StampInfo{
+			{"g1.a.png", "a", 0, 0, 24, 24, nil},
+			{"img/g1.b c.png", "b c", 24, 0, 16, 16, nil},
+		},
+	}}
+
+	It("SCSS", func() {
+		buf := bytes.Buffer{}
+		Ω(WriteSCSS(&buf, sheets)).Should(Succeed())
+		Ω(buf.String()).Should(Equal(`// Generated by spriter, DO NOT EDIT!
+
+$g1-sprite-url: "../img/g1.png";
+$g1-a-x: 0px;
+$g1-a-y: 0px;
+$g1-a-width: 24px;
+$g1-a-height: 24px;
+$g1-b-c-x: 24px;
+$g1-b-c-y: 0px;
+$g1-b-c-width: 16px;
+$g1-b-c-height: 16px;
+$g1-icons: (
+  a: (x: $g1-a-x, y: $g1-a-y, width: $g1-a-width, height: $g1-a-height),
+  b-c: (x: $g1-b-c-x, y: $g1-b-c-y, width: $g1-b-c-width, height: $g1-b-c-height),
+);
+@mixin g1-icon($name) {
+  $icon: map-get($g1-icons, $name);
+  background: url($g1-sprite-url) no-repeat (-1 * map-get($icon, x)) (-1 * map-get($icon, y));
+  width: map-get($icon, width);
+  height: map-get($icon, height);
+}
+`))
+	})
+
+	It("Less", func() {
+		buf := bytes.Buffer{}
+		Ω(WriteLess(&buf, sheets)).Should(Succeed())
+		Ω(buf.String()).Should(Equal(`// Generated by spriter, DO NOT EDIT!
+
+@g1-sprite-url: "../img/g1.png";
+@g1-a-x: 0px;
+@g1-a-y: 0px;
+@g1-a-width: 24px;
+@g1-a-height: 24px;
+@g1-b-c-x: 24px;
+@g1-b-c-y: 0px;
+@g1-b-c-width: 16px;
+@g1-b-c-height: 16px;
+.g1-icon(@name) {
+  @x: "g1-@{name}-x";
+  @y: "g1-@{name}-y";
+  @width: "g1-@{name}-width";
+  @height: "g1-@{name}-height";
+  background: url(@g1-sprite-url) no-repeat (-1 * @@x) (-1 * @@y);
+  width: @@width;
+  height: @@height;
+}
+`))
+	})
+
+	It("Gen writes SCSS and Less", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		s := New(`.foo { background: url(g1.t1.png); }`, ts)
+		s.SCSSFile, s.LessFile = "_sprites.scss", "sprites.less"
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(ts.files["_sprites.scss"].String()).Should(ContainSubstring("$g1-t1-width: 16px;"))
+		Ω(ts.files["sprites.less"].String()).Should(ContainSubstring("@g1-t1-width: 16px;"))
+	})
+
+})
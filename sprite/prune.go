@@ -0,0 +1,129 @@
+package sprite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/redforks/errors"
+)
+
+// pruneManifestVersion is the version of PruneManifest format.
+const pruneManifestVersion = 1
+
+// FileRemover is implemented by Service able to remove generated files, used
+// to prune stale sprites.
+type FileRemover interface {
+	// RemoveFile removes file at path, as passed to CreateSpriteImage() or
+	// CreateFile(). Not exist file is not an error.
+	RemoveFile(path string) error
+}
+
+// PruneManifest records sprite images and atlas files generated by previous
+// builds, so that sprites no longer generated, left behind because of their
+// content hashed names, can be removed without touching files not created by
+// Spriter.
+type PruneManifest struct {
+	Version int `json:"version"`
+
+	// Dir identifies the output directory Files are in, such as its absolute
+	// path. Files are relative to it.
+	Dir   string   `json:"dir"`
+	Files []string `json:"files"` // sorted
+}
+
+// NewPruneManifest creates an empty PruneManifest of output directory dir.
+func NewPruneManifest(dir string) *PruneManifest {
+	return &PruneManifest{Version: pruneManifestVersion, Dir: dir}
+}
+
+// ReadPruneManifest reads PruneManifest written by WritePruneManifest() from
+// r. Manifest of other version is an error, rather than guessing which files
+// to remove. Returns an empty PruneManifest if r records other output
+// directory than dir, its files were not created in dir.
+func ReadPruneManifest(r io.Reader, dir string) (*PruneManifest, error) {
+	m := &PruneManifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, errors.NewInput(fmt.Errorf("bad prune manifest: %v", err))
+	}
+	if m.Version != pruneManifestVersion {
+		return nil, errors.Inputf("unsupported prune manifest version %d", m.Version)
+	}
+	if m.Dir != dir {
+		return NewPruneManifest(dir), nil
+	}
+	return m, nil
+}
+
+// WritePruneManifest writes m to w in JSON.
+func WritePruneManifest(w io.Writer, m *PruneManifest) error {
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.NewBug(err)
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// Prune removes stale files, recorded in m but not in current, by remover.
+// Returns stale files sorted, nothing removed if dryRun. m updated to record
+// current files, and stale files not removed, so they are pruned next time.
+// Files recorded in m not relative to the output directory, such as "../a.png",
+// are never removed.
+func (m *PruneManifest) Prune(remover FileRemover, current []string, dryRun bool) (stale []string, err error) {
+	keep := make(map[string]bool, len(current))
+	for _, f := range current {
+		keep[f] = true
+	}
+	for _, f := range m.Files {
+		if !keep[f] && !escapesBase(path.Clean(f)) {
+			stale = append(stale, f)
+		}
+	}
+	sort.Strings(stale)
+
+	files := append([]string(nil), current...)
+	for _, f := range stale {
+		if dryRun {
+			files = append(files, f)
+			continue
+		}
+		if e := remover.RemoveFile(f); e != nil {
+			files = append(files, f)
+			if err == nil {
+				err = e
+			}
+		}
+	}
+
+	m.Files = distinctSorted(files)
+	return stale, err
+}
+
+func distinctSorted(items []string) []string {
+	r := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			r = append(r, item)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+// Generated returns sprite images and atlas files generated by last Gen()
+// call, sprites loaded from Cache included. Record them in PruneManifest.
+func (s *Spriter) Generated() []string {
+	var files []string
+	for _, sheet := range s.sheets {
+		files = append(files, sheet.File)
+		for _, format := range s.AtlasFormats {
+			files = append(files, AtlasFile(sheet, format))
+		}
+	}
+	return files
+}
@@ -0,0 +1,99 @@
+package sprite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prune", func() {
+	var (
+		sv *MemoryService
+		m  *PruneManifest
+	)
+
+	BeforeEach(func() {
+		sv = NewMemoryService(nil)
+		for _, f := range []string{"a.png", "b.png", "b.json", "other.png"} {
+			sv.CreateSpriteImage(f)
+		}
+		m = NewPruneManifest("/out")
+		m.Files = []string{"a.png", "b.json", "b.png", "../c.png"}
+	})
+
+	It("Remove stale", func() {
+		stale, err := m.Prune(sv, []string{"a.png", "d.png"}, false)
+		Ω(err).Should(Succeed())
+		Ω(stale).Should(Equal([]string{"b.json", "b.png"}))
+		Ω(sv.Sprites()).Should(HaveLen(2))
+		Ω(sv.SpriteExists("other.png")).Should(BeTrue())
+		Ω(m.Files).Should(Equal([]string{"a.png", "d.png"}))
+	})
+
+	It("Dry run", func() {
+		stale, err := m.Prune(sv, []string{"a.png", "d.png"}, true)
+		Ω(err).Should(Succeed())
+		Ω(stale).Should(Equal([]string{"b.json", "b.png"}))
+		Ω(sv.Sprites()).Should(HaveLen(4))
+		Ω(m.Files).Should(Equal([]string{"a.png", "b.json", "b.png", "d.png"}))
+	})
+
+	It("Remove failed", func() {
+		stale, err := m.Prune(failRemover{}, []string{"a.png"}, false)
+		Ω(err).Should(MatchError("remove failed"))
+		Ω(stale).Should(Equal([]string{"b.json", "b.png"}))
+		Ω(m.Files).Should(Equal([]string{"a.png", "b.json", "b.png"}))
+	})
+
+	It("Read write", func() {
+		buf := &bytes.Buffer{}
+		Ω(WritePruneManifest(buf, m)).Should(Succeed())
+		Ω(ReadPruneManifest(bytes.NewReader(buf.Bytes()), "/out")).Should(Equal(m))
+
+		_, err := ReadPruneManifest(strings.NewReader("{"), "/out")
+		Ω(err).Should(HaveOccurred())
+		_, err = ReadPruneManifest(strings.NewReader(`{"version": 2, "files": ["a.png"]}`), "/out")
+		Ω(err).Should(MatchError("unsupported prune manifest version 2"))
+	})
+
+	It("Other output directory", func() {
+		buf := &bytes.Buffer{}
+		Ω(WritePruneManifest(buf, m)).Should(Succeed())
+		other, err := ReadPruneManifest(buf, "/other")
+		Ω(err).Should(Succeed())
+		Ω(other).Should(Equal(NewPruneManifest("/other")))
+
+		stale, err := other.Prune(sv, []string{"a.png"}, false)
+		Ω(err).Should(Succeed())
+		Ω(stale).Should(BeEmpty())
+		Ω(sv.Sprites()).Should(HaveLen(4))
+	})
+
+	It("Generated", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g2.t2.png": "t2.png",
+		})
+		s := New(`
+	.a { background: url(g1.t1.png); }
+	.b { background: url(g2.t2.png); }`, ts)
+		s.AtlasFormats = []AtlasFormat{AtlasJSONHash}
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+
+		files := s.Generated()
+		Ω(files).Should(HaveLen(4))
+		Ω(files[0]).Should(Equal(s.Sheets()[0].File))
+		Ω(files[1]).Should(Equal(AtlasFile(s.Sheets()[0], AtlasJSONHash)))
+	})
+
+})
+
+type failRemover struct{}
+
+func (failRemover) RemoveFile(path string) error {
+	return fmt.Errorf("remove failed")
+}
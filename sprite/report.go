@@ -0,0 +1,211 @@
+package sprite
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/redforks/css/writer"
+	"github.com/redforks/errors"
+)
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sprites</title>
+{{if .Stylesheet}}<link rel="stylesheet" href="{{.Stylesheet}}">
+{{else}}<style>
+{{.CSS}}
+</style>
+{{end}}<style>
+body { font-family: sans-serif; }
+.spriter-sheet > img { border: 1px dashed #ccc; }
+.spriter-sheet table { border-collapse: collapse; }
+.spriter-sheet th, .spriter-sheet td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+</style>
+</head>
+<body>
+<h1>Sprites</h1>
+{{range .Sheets}}
+<section class="spriter-sheet">
+<h2>{{.Group}}: {{.File}} ({{.Width}}x{{.Height}})</h2>
+<img src="{{.Src}}" alt="{{.File}}">
+<table>
+<tr><th>Icon</th><th>Selectors</th><th>Source</th><th>Size</th><th>Offset</th></tr>
+{{range .Stamps}}<tr>
+<td>{{.Preview}}</td>
+<td>{{range .Selectors}}<code>{{.}}</code><br>{{end}}</td>
+<td>{{.Path}}</td>
+<td>{{.Width}}x{{.Height}}</td>
+<td>{{.X}}, {{.Y}}</td>
+</tr>
+{{end}}</table>
+</section>
+{{end}}
+</body>
+</html>
+`))
+
+type reportData struct {
+	Stylesheet string
+	CSS        template.CSS
+	Sheets     []*reportSheet
+}
+
+type reportSheet struct {
+	*Sheet
+	Src    template.URL
+	Stamps []*reportStamp
+}
+
+type reportStamp struct {
+	*StampInfo
+	Preview template.HTML
+}
+
+// WriteReport writes HTML catalogue of sheets to w, showing each sprite, and
+// each image inside it with its selectors, source file, size and offset.
+//
+// Images are previewed by elements matching their selectors, styled by the
+// rewritten css: linked from stylesheet if not empty, otherwise css inlined.
+// Only simple selectors such as ".foo", "i.foo" or "#bar.foo" can be
+// previewed this way, others are previewed by inline style.
+func WriteReport(w io.Writer, sheets []*Sheet, stylesheet, css string) error {
+	data := &reportData{
+		Stylesheet: stylesheet,
+		CSS:        template.CSS(css),
+	}
+	for _, sheet := range sheets {
+		rs := &reportSheet{Sheet: sheet, Src: template.URL(sheet.URL)}
+		if sheet.content != nil {
+			rs.Src = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(sheet.content))
+		}
+		for _, st := range sheet.Stamps {
+			rs.Stamps = append(rs.Stamps, &reportStamp{st, previewElement(sheet, st, rs.Src)})
+		}
+		data.Sheets = append(data.Sheets, rs)
+	}
+
+	if err := reportTemplate.Execute(w, data); err != nil {
+		return errors.NewRuntime(err)
+	}
+	return nil
+}
+
+var simpleSelector = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*)?((?:[.#][a-zA-Z_][a-zA-Z0-9_-]*)+)$`)
+
+var selectorPart = regexp.MustCompile(`[.#][^.#]+`)
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// Returns html element previews the stamp, matches its first simple
+// selector, falls back to inline style if it has no simple selector.
+func previewElement(sheet *Sheet, st *StampInfo, src template.URL) template.HTML {
+	size := fmt.Sprintf("display: inline-block; width: %dpx; height: %dpx;", st.Width, st.Height)
+	for _, sel := range st.Selectors {
+		tag, attrs, ok := selectorElement(sel)
+		if !ok {
+			continue
+		}
+		if sheet.BaseSelector != "" {
+			_, baseAttrs, ok := selectorElement(sheet.BaseSelector)
+			if !ok {
+				continue
+			}
+			attrs = mergeAttrs(baseAttrs, attrs)
+		}
+		return template.HTML(fmt.Sprintf(`<%s%s style="%s"></%s>`, tag, formatAttrs(attrs), size, tag))
+	}
+
+	return template.HTML(fmt.Sprintf(`<span title="inline preview" style="%s background: url(&quot;%s&quot;) no-repeat %s;"></span>`,
+		size, html.EscapeString(string(src)), position(st.X, st.Y)))
+}
+
+// Parse simple selector to element tag and id, class attributes. Tag
+// defaults to span.
+func selectorElement(sel string) (tag string, attrs map[string][]string, ok bool) {
+	m := simpleSelector.FindStringSubmatch(sel)
+	if m == nil || voidElements[strings.ToLower(m[1])] {
+		return "", nil, false
+	}
+
+	tag = "span"
+	if m[1] != "" {
+		tag = m[1]
+	}
+	attrs = make(map[string][]string)
+	for _, part := range selectorPart.FindAllString(m[2], -1) {
+		if part[0] == '.' {
+			attrs["class"] = append(attrs["class"], part[1:])
+		} else {
+			attrs["id"] = []string{part[1:]}
+		}
+	}
+	return tag, attrs, true
+}
+
+func mergeAttrs(a, b map[string][]string) map[string][]string {
+	r := make(map[string][]string)
+	for k, v := range a {
+		r[k] = append(r[k], v...)
+	}
+	for k, v := range b {
+		if k == "id" {
+			r[k] = v
+			continue
+		}
+		r[k] = append(r[k], v...)
+	}
+	return r
+}
+
+func formatAttrs(attrs map[string][]string) string {
+	var buf strings.Builder
+	for _, k := range []string{"id", "class"} {
+		if v := attrs[k]; len(v) != 0 {
+			fmt.Fprintf(&buf, ` %s="%s"`, k, html.EscapeString(strings.Join(v, " ")))
+		}
+	}
+	return buf.String()
+}
+
+func (s *Spriter) writeReport(css string) (err error) {
+	f, err := s.createFile(s.ReportFile)
+	if err != nil {
+		return err
+	}
+	defer closeWriter(f, &err)
+	return WriteReport(f, s.reportSheets(), s.ReportStylesheet, css)
+}
+
+// Returns sheets for report. Sprite loaded from Cache has no content for data
+// uri, its url, relative to output css, is rebased to be relative to report.
+func (s *Spriter) reportSheets() []*Sheet {
+	if s.ReportStylesheet == "" {
+		return s.sheets
+	}
+
+	sheets := make([]*Sheet, len(s.sheets))
+	for i, sheet := range s.sheets {
+		sheets[i] = sheet
+		if sheet.content != nil {
+			continue
+		}
+		if uri, ok := rebaseURI(writer.FormatURI(sheet.URL), path.Dir(s.ReportStylesheet)); ok {
+			rebased := *sheet
+			rebased.URL = uri
+			sheets[i] = &rebased
+		}
+	}
+	return sheets
+}
@@ -0,0 +1,100 @@
+package sprite
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Report", func() {
+	var sheet *Sheet
+
+	BeforeEach(func() {
+		sheet = &Sheet{
+			Group:  "g1",
+			File:   "g1.png",
+			URL:    "../img/g1.png",
+			Width:  40,
+			Height: 24,
+			Stamps: []*StampInfo{
+				{"g1.a.png", "a", 0, 0, 24, 24, []string{".foo:hover", "i.foo#bar"}},
+				{"img/g1.b.png", "b", 24, 0, 16, 16, []string{"div > .b"}},
+			},
+		}
+	})
+
+	write := func(stylesheet, css string) string {
+		buf := bytes.Buffer{}
+		Ω(WriteReport(&buf, []*Sheet{sheet}, stylesheet, css)).Should(Succeed())
+		return buf.String()
+	}
+
+	It("Link stylesheet", func() {
+		r := write("../css/out.css", ".foo {}")
+		Ω(r).Should(ContainSubstring(`<link rel="stylesheet" href="../css/out.css">`))
+		Ω(r).ShouldNot(ContainSubstring(".foo {}"))
+		Ω(r).Should(ContainSubstring(`<h2>g1: g1.png (40x24)</h2>`))
+		Ω(r).Should(ContainSubstring(`<img src="../img/g1.png" alt="g1.png">`))
+		Ω(r).Should(ContainSubstring(`<td><code>.foo:hover</code><br><code>i.foo#bar</code><br></td>`))
+		Ω(r).Should(ContainSubstring(`<td>img/g1.b.png</td>`))
+		Ω(r).Should(ContainSubstring(`<td>16x16</td>`))
+		Ω(r).Should(ContainSubstring(`<td>24, 0</td>`))
+	})
+
+	It("Inline css", func() {
+		Ω(write("", ".foo { color: red; }")).Should(ContainSubstring("<style>\n.foo { color: red; }\n</style>"))
+	})
+
+	It("Preview by selector", func() {
+		r := write("", "")
+		Ω(r).Should(ContainSubstring(`<td><i id="bar" class="foo" style="display: inline-block; width: 24px; height: 24px;"></i></td>`))
+	})
+
+	It("Preview by inline style", func() {
+		r := write("", "")
+		Ω(r).Should(ContainSubstring(`<span title="inline preview" style="display: inline-block; width: 16px; height: 16px; background: url(&quot;../img/g1.png&quot;) no-repeat -24px 0;"></span>`))
+	})
+
+	It("Preview with base selector", func() {
+		sheet.BaseSelector = ".icon-g1"
+		sheet.Stamps[1].Selectors = []string{".icon-g1-b"}
+		Ω(write("", "")).Should(ContainSubstring(`<span class="icon-g1 icon-g1-b" style="display: inline-block; width: 16px; height: 16px;"></span>`))
+	})
+
+	It("Gen writes report", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		s := New(`.foo { background: url(g1.t1.png); }`, ts)
+		s.ReportFile = "report.html"
+		css, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		r := ts.files["report.html"].String()
+		Ω(r).Should(ContainSubstring(css))
+		Ω(r).Should(ContainSubstring(`<img src="data:image/png;base64,`))
+		Ω(r).Should(ContainSubstring(`<span class="foo" style=`))
+	})
+
+	It("Gen with Cache", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		cache := NewCache("")
+		gen := func() (string, string) {
+			s := New(`.foo { background: url(g1.t1.png); }`, ts)
+			s.Cache = cache
+			s.ReportFile = "report/index.html"
+			s.ReportStylesheet = "../out/a.css"
+			_, _, err := s.Gen()
+			Ω(err).Should(Succeed())
+			return ts.files["report/index.html"].String(), s.Sheets()[0].File
+		}
+
+		r, _ := gen()
+		Ω(r).Should(ContainSubstring(`<img src="data:image/png;base64,`))
+		r, file := gen()
+		Ω(r).Should(ContainSubstring(`<img src="../out/` + file + `"`))
+	})
+
+})
@@ -0,0 +1,127 @@
+package sprite
+
+import (
+	"strings"
+
+	"github.com/redforks/css-1/scanner"
+)
+
+// ruleTracker tracks the rule each css token belongs to, by feeding it the
+// token stream in order.
+type ruleTracker struct {
+	prelude   []*scanner.Token // tokens since last '{', '}' or ';'
+	selectors []string         // selector of each open block, "" for at-rule
+	starts    []*scanner.Token // first prelude token of each open block
+}
+
+func (r *ruleTracker) next(tk *scanner.Token) {
+	if tk.Type == scanner.TokenChar {
+		switch tk.Value {
+		case "{":
+			r.selectors = append(r.selectors, preludeSelector(r.prelude))
+			r.starts = append(r.starts, preludeStart(r.prelude))
+			r.prelude = nil
+			return
+		case "}":
+			if len(r.selectors) != 0 {
+				r.selectors = r.selectors[:len(r.selectors)-1]
+				r.starts = r.starts[:len(r.starts)-1]
+			}
+			r.prelude = nil
+			return
+		case ";":
+			r.prelude = nil
+			return
+		}
+	}
+	r.prelude = append(r.prelude, tk)
+}
+
+// Returns selector of the innermost rule, "" if not inside a style rule.
+func (r *ruleTracker) selector() string {
+	if len(r.selectors) == 0 {
+		return ""
+	}
+	return r.selectors[len(r.selectors)-1]
+}
+
+// Returns the first token of the innermost rule, whitespace and comments
+// before it excluded, nil if not inside a rule.
+func (r *ruleTracker) start() *scanner.Token {
+	if len(r.starts) == 0 {
+		return nil
+	}
+	return r.starts[len(r.starts)-1]
+}
+
+// Returns true if inside a top level style rule, not nested in at-rules such
+// as @media.
+func (r *ruleTracker) topLevel() bool {
+	return len(r.selectors) == 1 && r.selectors[0] != ""
+}
+
+// Returns selector text of rule prelude tokens, whitespace collapsed and
+// comments removed. Returns "" for at-rule prelude.
+func preludeSelector(tks []*scanner.Token) string {
+	buf := strings.Builder{}
+	for _, tk := range tks {
+		switch tk.Type {
+		case scanner.TokenAtKeyword:
+			if buf.Len() == 0 {
+				return ""
+			}
+			buf.WriteString(tk.Value)
+		case scanner.TokenS:
+			if buf.Len() != 0 {
+				buf.WriteByte(' ')
+			}
+		case scanner.TokenComment:
+		default:
+			buf.WriteString(tk.Value)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// Returns the first token of rule prelude not whitespace or comment, nil if
+// none.
+func preludeStart(tks []*scanner.Token) *scanner.Token {
+	if i := skipBlank(tks, 0, 1); i < len(tks) {
+		return tks[i]
+	}
+	return nil
+}
+
+// Returns the property ident token if url token at tks[i] is the only value
+// of a declaration, such as "background: url(foo.png);", otherwise nil.
+func soleValueProperty(tks []*scanner.Token, i int) *scanner.Token {
+	j := skipBlank(tks, i+1, 1)
+	if j < len(tks) && !isChar(tks[j], ";") && !isChar(tks[j], "}") {
+		return nil
+	}
+
+	j = skipBlank(tks, i-1, -1)
+	if j < 0 || !isChar(tks[j], ":") {
+		return nil
+	}
+	j = skipBlank(tks, j-1, -1)
+	if j < 0 || tks[j].Type != scanner.TokenIdent {
+		return nil
+	}
+	return tks[j]
+}
+
+// Skip whitespace and comment tokens from tks[i] in direction step, returns
+// index of the first other token, may be out of range.
+func skipBlank(tks []*scanner.Token, i, step int) int {
+	for ; i >= 0 && i < len(tks); i += step {
+		if tks[i].Type != scanner.TokenS && tks[i].Type != scanner.TokenComment {
+			break
+		}
+	}
+	return i
+}
+
+func isChar(tk *scanner.Token, c string) bool {
+	return tk.Type == scanner.TokenChar && tk.Value == c
+}
@@ -2,16 +2,17 @@ package sprite
 
 import (
 	"bytes"
-	"crypto/md5"
-	"encoding/base64"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
 	"io"
-	"log"
+	"io/ioutil"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/redforks/css-1/scanner"
 	"github.com/redforks/css/writer"
@@ -24,26 +25,116 @@ type Service interface {
 	// in css. Spriter will close the io.Reader if it also implements io.Closer.
 	OpenImage(path string) (io.Reader, error)
 
-	// Create sprite image file return as io.Writer. Spriter will close the
-	// io.Writer if it also implements io.Closer. path is relative to output css
-	// file.
-	CreateSpriteImage(path string) (io.Writer, error)
+	OutputService
 }
 
 // Generate css sprite image by scan .css file, generate updated .css file.
 //
-// Image must use relative path, absolute path or other web site report as warning.
+// Image must use relative path, absolute path or other web site report as
+// warning Diagnostic.
 //
 // Only png file supported, ignore other image file format.
 //
 // Image file name need to be in [Group].[Name].png format, images with the
 // same group name will generate a sprite image [Group].png. Images without
 // group name leave it untouched.
+//
+// Query string and fragment of image url, such as "g.a.png?v=3", are ignored
+// when loading and grouping images.
 type Spriter struct {
+	// KeepQuery appends query string of the original image url to the
+	// rewritten sprite url: url(g.a.png?v=3) becomes url([hash].png?v=3).
+	KeepQuery bool
+
+	// URLPrefix is prepended to sprite file name in rewritten css, such as
+	// "../img" or "https://cdn.example.com/img/". Sprite referenced by bare
+	// file name if empty, i.e. in the same directory as output css.
+	URLPrefix string
+
+	// Namer decides sprite file names, default to DefaultNameTemplate.
+	Namer Namer
+
+	// Order of stamps inside sprite, default to OrderCSS. Use OrderName or
+	// OrderSize to keep sprite unchanged when css rules are reordered.
+	Order StampOrder
+
+	// ManifestFile, if not empty, is the path of JSON manifest file describing
+	// generated sprites and stamps inside them, created by Service, which must
+	// implement FileCreator.
+	ManifestFile string
+
+	// SCSSFile and LessFile, if not empty, are paths of SCSS partial and Less
+	// file defining variables and mixins of generated sprites, see WriteSCSS()
+	// and WriteLess(). Created by Service, which must implement FileCreator.
+	SCSSFile, LessFile string
+
+	// AtlasFormats, texture atlas files exported for each sprite, named by
+	// AtlasFile(), created by Service, which must implement FileCreator.
+	AtlasFormats []AtlasFormat
+
+	// ShareBase emits one rule for each sprite, selectors of all sprited
+	// declarations comma joined, carrying background-image and
+	// background-repeat, and reduces each declaration to background-position.
+	// Only applies to declarations in top level rules having url() as the only
+	// value, such as "background: url(g.a.png)", others rewritten as usual.
+	//
+	// The shared rule is emitted right before the first rule using the sprite,
+	// so it does not override earlier rules. But it changes the cascade for
+	// later sprited rules: a background shorthand between them, such as
+	// ".btn { background: #ccc }", now resets their background-image if it
+	// applies to the same element. Do not enable ShareBase for such css.
+	ShareBase bool
+
+	// ReportFile, if not empty, is the path of HTML catalogue of generated
+	// sprites, see WriteReport(). Created by Service, which must implement
+	// FileCreator.
+	ReportFile string
+
+	// ReportStylesheet is the url of output css file relative to ReportFile,
+	// if empty, output css is inlined in the report, which works only if they
+	// are in the same directory.
+	ReportStylesheet string
+
+	// Budget limits every sprite, unless overridden by GroupBudgets, which
+	// is keyed by group name. Gen() aborts with BudgetError if a sprite
+	// exceeds it.
+	Budget       Budget
+	GroupBudgets map[string]Budget
+
+	// TotalBudget limits all sprites together: MaxBytes and MaxStamps are
+	// compared to the sum of all sprites, MaxWidth and MaxHeight to the
+	// largest one. Gen() aborts with BudgetError if exceeded.
+	TotalBudget Budget
+
+	// Cache, if not nil, skips regenerating sprites unchanged since last
+	// build, and is updated by Gen(). See Cache.
+	Cache *Cache
+
+	// Rebase is the slash separated path from output css directory to input
+	// css directory, such as "../src". If not empty, relative urls not
+	// sprited are prefixed with it, to keep them valid when output css is
+	// saved to other directory. Absolute, data: and remote urls are untouched.
+	Rebase string
+
 	css string
 	sv  Service
 
 	loadedImages map[string]*stamp
+	images       []string // referenced images, in order
+	referenced   map[string]bool
+	diags        []*Diagnostic
+	sheets       []*Sheet
+	stats        []*Stats
+
+	// selectors of sprited declarations in more than one group, not
+	// shareable in ShareBase mode
+	multiGroup map[string]bool
+
+	// Spriter created by NewFromImages(): listed images, and selector
+	// templates of generated stylesheet
+	fromImages             bool
+	listed                 []string
+	selector, baseSelector string
 }
 
 // Create Spriter.
@@ -59,122 +150,496 @@ func New(css string, service Service) *Spriter {
 
 // Do the generation, return translated css file content. Generated sprite
 // image files are saved using Service interface.
-func (s *Spriter) Gen() (css string, err error) {
-	var tks []*scanner.Token
-	tks, err = scan(s.css)
+//
+// diags contains all warnings and errors found, if generation aborted by an
+// Error diagnostic, it is also returned as err wrapped as errors.ByInput.
+func (s *Spriter) Gen() (css string, diags []*Diagnostic, err error) {
+	s.diags, s.images, s.referenced = nil, nil, make(map[string]bool)
+	defer func() {
+		diags = s.diags
+	}()
+
+	if err = s.checkFileCreator(); err != nil {
+		return
+	}
+
+	var (
+		tks    []*scanner.Token
+		groups map[string][]*cssImage
+	)
+	if s.fromImages {
+		groups, err = s.listedGroups()
+	} else if tks, err = s.scan(); err == nil {
+		groups, err = s.cssGroups(tks)
+	}
 	if err != nil {
 		return
 	}
 
-	needTranslate := false
+	s.sheets, s.stats = nil, nil
+	s.multiGroup = multiGroupSelectors(groups)
+	for _, g := range sortedGroups(groups) {
+		var sheet *Sheet
+		if sheet, err = s.genSprite(g, groups[g]); err != nil {
+			return
+		}
+		s.sheets = append(s.sheets, sheet)
+		if err = s.writeAtlases(sheet); err != nil {
+			return
+		}
+	}
+	s.pruneCache(groups)
+
+	if s.ManifestFile != "" {
+		if err = s.writeManifest(); err != nil {
+			return
+		}
+	}
+	if s.SCSSFile != "" {
+		if err = s.writePreprocessorFile(s.SCSSFile, WriteSCSS); err != nil {
+			return
+		}
+	}
+	if s.LessFile != "" {
+		if err = s.writePreprocessorFile(s.LessFile, WriteLess); err != nil {
+			return
+		}
+	}
+
+	if css, err = s.output(tks, groups); err != nil {
+		return
+	}
+
+	if s.ReportFile != "" {
+		err = s.writeReport(css)
+	}
+	return
+}
+
+// Returns sprited images of css tokens keyed by group, rebases other urls.
+func (s *Spriter) cssGroups(tks []*scanner.Token) (map[string][]*cssImage, error) {
+	needTranslate, importing := false, false
 	groups := make(map[string][]*cssImage)
-	for _, tk := range tks {
+	rules := ruleTracker{}
+	for i, tk := range tks {
+		rules.next(tk)
 		switch tk.Type {
+		case scanner.TokenS, scanner.TokenComment:
+			continue
+		case scanner.TokenAtKeyword:
+			importing = strings.EqualFold(tk.Value, "@import")
+			continue
+		case scanner.TokenString:
+			if importing {
+				s.rebaseString(tk)
+			}
 		case scanner.TokenIdent:
 			needTranslate = tk.Value == "background"
 		case scanner.TokenURI:
 			if needTranslate {
-				var (
-					st *cssImage
-					g  string
-				)
-				if st, g, err = s.parseCssImage(tk); err != nil {
-					return
+				st, g, err := s.parseCssImage(tk)
+				if err != nil {
+					return nil, err
 				}
 
 				if st != nil {
+					st.selector = rules.selector()
+					if rules.topLevel() {
+						st.prop = soleValueProperty(tks, i)
+						st.rule = rules.start()
+					}
 					groups[g] = append(groups[g], st)
+					continue
 				}
 			}
+			s.rebase(tk)
 		}
+		importing = false
 	}
+	return groups, nil
+}
 
-	for _, sts := range groups {
-		size := getSpriteSize(sts)
-		var sprite = image.NewRGBA(image.Rectangle{Min: image.Point{}, Max: size})
-		for _, st := range sts {
-			b := st.img.bounds()
-			draw.Draw(sprite, b.Add(image.Pt(-st.img.sp.X, st.img.sp.Y)), st.img.img, b.Min, draw.Src)
+// Returns images listed by NewFromImages() keyed by group. Not from css,
+// their diagnostics have no position.
+func (s *Spriter) listedGroups() (map[string][]*cssImage, error) {
+	groups := make(map[string][]*cssImage)
+	for _, img := range s.listed {
+		g := extractGroup(img)
+		if g == "" {
+			continue
+		}
+		tk := &scanner.Token{Type: scanner.TokenURI}
+		st, err := s.parseImage(tk, cleanImagePath(img))
+		if err != nil {
+			return nil, err
 		}
+		groups[g] = append(groups[g], &cssImage{tk: tk, img: st})
+	}
+	return groups, nil
+}
 
-		hash := md5.New()
-		buf := &bytes.Buffer{}
-		w := io.MultiWriter(buf, hash)
-		if err = png.Encode(w, sprite); err != nil {
-			err = errors.NewRuntime(err)
-			return
+// Returns error if generated files other than sprites configured, but Service
+// not implement FileCreator to create them.
+func (s *Spriter) checkFileCreator() error {
+	if s.ManifestFile == "" && s.SCSSFile == "" && s.LessFile == "" && s.ReportFile == "" &&
+		len(s.AtlasFormats) == 0 {
+		return nil
+	}
+	if fileCreator(s.sv) == nil {
+		return errors.Bug("Service not implement FileCreator, needed by ManifestFile, SCSSFile, LessFile, ReportFile or AtlasFormats")
+	}
+	return nil
+}
+
+// Creates generated file other than sprite image by Service.
+func (s *Spriter) createFile(path string) (io.Writer, error) {
+	if c := fileCreator(s.sv); c != nil {
+		return c.CreateFile(path)
+	}
+	return nil, errors.Bug("Service not implement FileCreator")
+}
+
+// Returns generated css from rewritten tokens.
+func (s *Spriter) output(tks []*scanner.Token, groups map[string][]*cssImage) (string, error) {
+	if s.fromImages {
+		return WriteStylesheet(s.sheets, s.selector, s.baseSelector), nil
+	}
+
+	if !s.ShareBase {
+		return writer.Dumps(tks)
+	}
+
+	shared := s.sharedRules(groups)
+	buf := bytes.Buffer{}
+	w := writer.New(&buf)
+	for _, tk := range tks {
+		if rule, ok := shared[tk]; ok {
+			buf.WriteString(rule)
 		}
-		token := base64.URLEncoding.EncodeToString(hash.Sum(nil)[:6])
+		w.Write(tk)
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
-		var f io.Writer
-		f, err = s.sv.CreateSpriteImage(token + ".png")
-		if err != nil {
-			return
+// Images returns paths of grouped images referenced by css in last Gen()
+// call, in the order first referenced, including the one failed to load. Gen()
+// aborted by an error may not see all of them.
+func (s *Spriter) Images() []string {
+	return s.images
+}
+
+// Sheets returns sprites generated by last Gen() call, ordered by group name.
+func (s *Spriter) Sheets() []*Sheet {
+	return s.sheets
+}
+
+// Generate sprite image of a group, save it through Service, and rewrite css
+// tokens referencing it. Sprite unchanged since last build is loaded from
+// Cache instead.
+func (s *Spriter) genSprite(group string, imgs []*cssImage) (*Sheet, error) {
+	sts := distinctStamps(imgs)
+	key := s.cacheKey(sts)
+	out, ok := s.fromCache(group, key, sts)
+	if !ok {
+		var err error
+		if out, err = s.drawSprite(group, sts); err != nil {
+			return nil, err
 		}
-		defer closeClosable(f)
-		if _, err = f.Write(buf.Bytes()); err != nil {
-			return
+	}
+
+	s.stats = append(s.stats, out.stats)
+	if exceeded := s.budget(group).check(out.stats); exceeded != nil {
+		return nil, errors.NewInput(&BudgetError{group, exceeded})
+	}
+	if exceeded := s.TotalBudget.check(totalStats(s.stats)); exceeded != nil {
+		return nil, errors.NewInput(&BudgetError{"", exceeded})
+	}
+
+	name := out.name
+	if out.content != nil {
+		name = s.namer().Name(group, out.content)
+		if err := s.writeSprite(name, out.content); err != nil {
+			return nil, err
+		}
+		s.cacheSprite(group, key, name, out)
+	}
+
+	for _, img := range imgs {
+		if s.shareable(img) {
+			img.prop.Value = "background-position"
+			img.tk.Value = position(-img.img.sp.X, img.img.sp.Y)
+			continue
 		}
 
-		for _, st := range sts {
-			st.tk.Value = "url(" + token + ".png) no-repeat"
-			if st.img.sp.X != 0 {
-				st.tk.Value += fmt.Sprintf(" %dpx 0", st.img.sp.X)
+		spriteURL := s.spriteURL(name)
+		if s.KeepQuery && img.query != "" {
+			spriteURL += "?" + img.query
+		}
+		img.tk.Value = writer.FormatURI(spriteURL) + " no-repeat"
+		if img.img.sp.X != 0 {
+			img.tk.Value += fmt.Sprintf(" %dpx 0", img.img.sp.X)
+		}
+	}
+
+	sheet := newSheet(group, name, s.spriteURL(name), out.size, out.stamps, imgs)
+	sheet.content = out.content
+	if s.fromImages {
+		sheet.BaseSelector = expandSelector(s.baseSelector, group, "")
+		for _, info := range sheet.Stamps {
+			info.Selectors = []string{expandSelector(s.selector, group, info.Name)}
+		}
+	}
+	return sheet, nil
+}
+
+// Sprite image of a group, generated or loaded from Cache.
+type spriteImage struct {
+	name    string // file name, empty if not saved yet
+	size    image.Point
+	stamps  []*stamp // in layout order
+	content []byte   // encoded image, nil if loaded from Cache
+	stats   *Stats
+}
+
+// Decode images of sts, layout and draw them into sprite image, and encode it.
+func (s *Spriter) drawSprite(group string, sts []*stamp) (*spriteImage, error) {
+	for _, st := range sts {
+		if err := s.decode(st); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	size := layout(sts, s.Order)
+	var sprite = image.NewRGBA(image.Rectangle{Min: image.Point{}, Max: size})
+	for _, st := range sts {
+		b := st.bounds()
+		draw.Draw(sprite, b.Add(image.Pt(-st.sp.X, st.sp.Y)), st.img, b.Min, draw.Src)
+	}
+	packTime := time.Since(start)
+
+	start = time.Now()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, sprite); err != nil {
+		return nil, errors.NewRuntime(err)
+	}
+	stats := newStats(group, sprite, sts, buf.Len())
+	stats.PackTime, stats.EncodeTime = packTime, time.Since(start)
+	return &spriteImage{
+		size:    size,
+		stamps:  sts,
+		content: buf.Bytes(),
+		stats:   stats,
+	}, nil
+}
+
+// Save encoded sprite image through Service.
+func (s *Spriter) writeSprite(name string, content []byte) (err error) {
+	f, err := s.sv.CreateSpriteImage(name)
+	if err != nil {
+		return err
+	}
+	defer closeWriter(f, &err)
+	_, err = f.Write(content)
+	return err
+}
+
+// Returns true if img declaration can be reduced to background-position in
+// ShareBase mode: it is the only value of background declaration in a top
+// level rule, no query string need to keep, and its selector not sprited in
+// other groups. Shared rules are not in source order, a selector in multiple
+// groups would get the image of one group and the position of another.
+func (s *Spriter) shareable(img *cssImage) bool {
+	return s.ShareBase && img.prop != nil && !(s.KeepQuery && img.query != "") &&
+		!s.multiGroup[img.selector]
+}
+
+// Returns selectors of declarations in more than one group.
+func multiGroupSelectors(groups map[string][]*cssImage) map[string]bool {
+	groupOf := make(map[string]string)
+	r := make(map[string]bool)
+	for g, imgs := range groups {
+		for _, img := range imgs {
+			if first, ok := groupOf[img.selector]; !ok {
+				groupOf[img.selector] = g
+			} else if first != g {
+				r[img.selector] = true
 			}
 		}
 	}
+	return r
+}
 
-	return writer.Dumps(tks)
+// Returns shared rules of ShareBase mode, one rule for each sprite, selectors
+// of all its shareable declarations comma joined. Keyed by the first token of
+// the rule containing the first shareable declaration of the sprite, the
+// shared rule goes right before it.
+func (s *Spriter) sharedRules(groups map[string][]*cssImage) map[*scanner.Token]string {
+	rules := make(map[*scanner.Token]string)
+	for _, sheet := range s.sheets {
+		var (
+			selectors []string
+			at        *scanner.Token
+		)
+		added := make(map[string]bool)
+		for _, img := range groups[sheet.Group] {
+			if s.shareable(img) && !added[img.selector] {
+				added[img.selector] = true
+				selectors = append(selectors, img.selector)
+				if at == nil {
+					at = img.rule
+				}
+			}
+		}
+		if len(selectors) == 0 {
+			continue
+		}
+
+		rules[at] += fmt.Sprintf("%s { background-image: %s; background-repeat: no-repeat; }\n",
+			strings.Join(selectors, ", "), writer.FormatURI(sheet.URL))
+	}
+	return rules
 }
 
-func getSpriteSize(imgs []*cssImage) image.Point {
-	p := image.Point{}
+func sortedGroups(groups map[string][]*cssImage) []string {
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns distinct stamps of imgs, in the order first referenced.
+func distinctStamps(imgs []*cssImage) []*stamp {
+	var sts []*stamp
+	added := make(map[*stamp]bool)
 	for _, img := range imgs {
-		st := img.img
-		if st.sp.X == -1 {
-			st.sp = image.Pt(-p.X, 0)
-			p.X += st.dx()
-			if p.Y < st.dy() {
-				p.Y = st.dy()
-			}
+		if !added[img.img] {
+			added[img.img] = true
+			sts = append(sts, img.img)
+		}
+	}
+	return sts
+}
+
+// Layout sts horizontally, sorted in place by order, returns the sprite size.
+func layout(sts []*stamp, order StampOrder) image.Point {
+	sortStamps(sts, order)
+
+	p := image.Point{}
+	for _, st := range sts {
+		st.sp = image.Pt(-p.X, 0)
+		p.X += st.dx()
+		if p.Y < st.dy() {
+			p.Y = st.dy()
 		}
 	}
 	return p
 }
 
-// Call .Close() if object implements io.Closer.
+// Call .Close() if object implements io.Closer, used on readers, close
+// error ignored.
 func closeClosable(o interface{}) {
-	closable, ok := o.(io.Closer)
-	if ok {
-		if err := closable.Close(); err != nil {
-			log.Println(err)
+	if closable, ok := o.(io.Closer); ok {
+		closable.Close()
+	}
+}
+
+// aborter is implemented by writers able to discard partially written
+// content, such as AtomicFile.
+type aborter interface {
+	Abort() error
+}
+
+// Close w created by Service, use with defer and named error result. If *err
+// is nil, close error returned through it. Otherwise writing failed, w
+// aborted if it implements Abort(), so that no partial file left. Write and
+// close errors both returned as runtime error.
+func closeWriter(w io.Writer, err *error) {
+	if *err != nil {
+		if _, ok := (*err).(*errors.Error); !ok {
+			*err = errors.NewRuntime(*err)
+		}
+		if a, ok := w.(aborter); ok {
+			a.Abort()
+			return
+		}
+	}
+
+	if closable, ok := w.(io.Closer); ok {
+		if e := closable.Close(); e != nil && *err == nil {
+			*err = errors.NewRuntime(e)
 		}
 	}
 }
 
-func scan(css string) ([]*scanner.Token, error) {
-	s := scanner.New(css)
+func (s *Spriter) scan() ([]*scanner.Token, error) {
+	sc := scanner.New(s.css)
 	tks := []*scanner.Token{}
 	for {
-		tk := s.Next()
+		tk := sc.Next()
 		switch tk.Type {
 		case scanner.TokenEOF:
 			return tks, nil
 		case scanner.TokenError:
-			return nil, errors.Input(tk.Value)
+			return nil, s.fail(tk, CodeSyntax, "%s", tk.Value)
 		default:
 			tks = append(tks, tk)
 		}
 	}
 }
 
-func extractUriFile(uri string) (file string, err error) {
-	s := uri[4 : len(uri)-1]
-	switch s[0] {
-	case '"', '\'':
-		s = s[1 : len(s)-1]
+// Returns true if uri refers to other web site, such as "http://foo.com/a.png"
+// or "//foo.com/a.png".
+func isRemoteURL(uri string) bool {
+	return strings.HasPrefix(uri, "//") || strings.Contains(uri, "://")
+}
+
+func (s *Spriter) namer() Namer {
+	if s.Namer == nil {
+		return defaultNamer
+	}
+	return s.Namer
+}
+
+// Returns url of sprite file name referenced in css.
+func (s *Spriter) spriteURL(name string) string {
+	if s.URLPrefix == "" || strings.HasSuffix(s.URLPrefix, "/") {
+		return s.URLPrefix + name
 	}
-	return s, nil
+	return s.URLPrefix + "/" + name
+}
+
+// Rewrite url token relative to Rebase path.
+func (s *Spriter) rebase(tk *scanner.Token) {
+	if s.Rebase == "" {
+		return
+	}
+
+	if uri, ok := rebaseURI(tk.Value, s.Rebase); ok {
+		tk.Value = writer.FormatURI(uri)
+	}
+}
+
+// Rebase string token of @import "foo.css" form.
+func (s *Spriter) rebaseString(tk *scanner.Token) {
+	if s.Rebase == "" {
+		return
+	}
+
+	if uri, ok := rebaseURI("url("+tk.Value+")", s.Rebase); ok {
+		tk.Value = writer.FormatString(uri)
+	}
+}
+
+// Returns canonical form of image path, "a/../g.x.png" and "./g.x.png" both
+// become "g.x.png". Used as image cache key and passed to Service.
+func cleanImagePath(p string) string {
+	return path.Clean(p)
 }
 
 // extract file name, expect [group].[name].png. Group name is empty string if
@@ -188,10 +653,24 @@ func extractGroup(path string) (group string) {
 	return words[0]
 }
 
+// extract image name from [group].[name].png file name.
+func extractName(path string) string {
+	words := strings.Split(filepath.Base(path), ".")
+	if len(words) != 3 {
+		return ""
+	}
+	return words[1]
+}
+
 // Represent a css image style
 type cssImage struct {
-	tk  *scanner.Token
-	img *stamp
+	tk    *scanner.Token
+	img   *stamp
+	query string // query string of image url
+
+	selector string         // selector of the rule, "" if not in style rule
+	prop     *scanner.Token // property token if url is the only value in top level rule
+	rule     *scanner.Token // first token of top level rule
 }
 
 // Represent a image inside sprite
@@ -199,6 +678,14 @@ type stamp struct {
 	filename string // Filename of the image
 	img      image.Image
 	sp       image.Point // Start position in sprite
+
+	tk   *scanner.Token // first token referencing the image
+	data []byte         // content of image file, released after decoded
+	hash string         // hash of image file content, set if Cache used
+	dims image.Point    // image size, set after decoded or loaded from Cache
+
+	fileSize   int64
+	decodeTime time.Duration
 }
 
 func (st *stamp) bounds() image.Rectangle {
@@ -206,18 +693,28 @@ func (st *stamp) bounds() image.Rectangle {
 }
 
 func (st *stamp) dx() int {
-	return st.img.Bounds().Dx()
+	return st.dims.X
 }
 
 func (st *stamp) dy() int {
-	return st.img.Bounds().Dy()
+	return st.dims.Y
 }
 
 // Parse stamp from a image url css token. stamp is nil if the url need
 // ignored: not png, not expected filename format.
 func (s *Spriter) parseCssImage(tk *scanner.Token) (cssImg *cssImage, groupName string, err error) {
-	var fn string
-	if fn, err = extractUriFile(tk.Value); err != nil {
+	fn, query, e := extractUriFile(tk.Value)
+	if e != nil {
+		s.warn(tk, CodeBadURL, "%v, ignored", e)
+		return
+	}
+
+	switch {
+	case isRemoteURL(fn):
+		s.warn(tk, CodeRemoteURL, "image %s is on other web site, ignored", fn)
+		return
+	case strings.HasPrefix(fn, "/"):
+		s.warn(tk, CodeAbsoluteURL, "image %s uses absolute path, ignored", fn)
 		return
 	}
 
@@ -227,37 +724,72 @@ func (s *Spriter) parseCssImage(tk *scanner.Token) (cssImg *cssImage, groupName
 	}
 
 	var st *stamp
-	if st, err = s.parseImage(fn); err != nil {
+	fn = cleanImagePath(fn)
+	if st, err = s.parseImage(tk, fn); err != nil {
 		return
 	}
 
 	cssImg = &cssImage{
-		tk,
-		st,
+		tk:    tk,
+		img:   st,
+		query: query,
 	}
 	return
 }
 
-func (s *Spriter) parseImage(imgFile string) (*stamp, error) {
+func (s *Spriter) parseImage(tk *scanner.Token, imgFile string) (*stamp, error) {
+	if !s.referenced[imgFile] {
+		s.referenced[imgFile] = true
+		s.images = append(s.images, imgFile)
+	}
 	if img, ok := s.loadedImages[imgFile]; ok {
 		return img, nil
 	}
 
-	if f, err := s.sv.OpenImage(imgFile); err != nil {
-		return nil, errors.NewInput(err)
-	} else {
-		defer closeClosable(f)
-
-		img, _, err := image.Decode(f)
-		if err != nil {
-			return nil, errors.NewInput(err)
-		}
-		st := &stamp{
-			imgFile,
-			img,
-			image.Pt(-1, -1),
+	f, err := s.sv.OpenImage(imgFile)
+	if err != nil {
+		if err == ErrPathEscapes {
+			return nil, s.fail(tk, CodePathEscapes, "image %s is outside of base paths", imgFile)
 		}
-		s.loadedImages[imgFile] = st
-		return st, nil
+		return nil, s.fail(tk, CodeOpenImage, "open image %s: %v", imgFile, err)
+	}
+	defer closeClosable(f)
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, s.fail(tk, CodeOpenImage, "read image %s: %v", imgFile, err)
+	}
+	st := &stamp{
+		filename: imgFile,
+		sp:       image.Pt(-1, -1),
+		tk:       tk,
+		data:     data,
+		fileSize: int64(len(data)),
+	}
+
+	// With Cache, decoding deferred until the sprite known to be changed.
+	if s.Cache != nil {
+		st.hash = contentHash(data)
+	} else if err = s.decode(st); err != nil {
+		return nil, err
+	}
+	s.loadedImages[imgFile] = st
+	return st, nil
+}
+
+// Decode image of st if not decoded yet.
+func (s *Spriter) decode(st *stamp) error {
+	if st.img != nil {
+		return nil
+	}
+
+	start := time.Now()
+	img, _, err := image.Decode(bytes.NewReader(st.data))
+	if err != nil {
+		return s.fail(st.tk, CodeDecodeImage, "decode image %s: %v", st.filename, err)
 	}
+	st.img, st.data = img, nil
+	st.dims = img.Bounds().Size()
+	st.decodeTime = time.Since(start)
+	return nil
 }
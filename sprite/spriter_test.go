@@ -11,6 +11,8 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	"github.com/redforks/errors"
 )
 
 var _ = Describe("sprite", func() {
@@ -165,6 +167,291 @@ var _ = Describe("sprite", func() {
 		ts.assertSprite("l01cVKU8.png", 32, 16)
 	})
 
+	It("url() with whitespace and escapes", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png":  "t1.png",
+			"g1.t 2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url( g1.t1.png ); }
+	.bar { background: url(g1.t\ 2.png); }
+	.foobar { background: url("g1.t%202.png"); }
+		`, ts)
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(l01cVKU8.png) no-repeat; }
+	.bar { background: url(l01cVKU8.png) no-repeat -16px 0; }
+	.foobar { background: url(l01cVKU8.png) no-repeat -16px 0; }
+		`))
+		ts.assertSprite("l01cVKU8.png", 32, 16)
+	})
+
+	It("Query string and fragment", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url(g1.t1.png?v=3); }
+	.bar { background: url(g1.t2.png#x); }
+	.foobar { background: url(g1.t1.png); }
+		`, ts)
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(l01cVKU8.png) no-repeat; }
+	.bar { background: url(l01cVKU8.png) no-repeat -16px 0; }
+	.foobar { background: url(l01cVKU8.png) no-repeat; }
+		`))
+		ts.assertSprite("l01cVKU8.png", 32, 16)
+	})
+
+	It("Keep query string", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url(g1.t1.png?v=3); }
+	.bar { background: url(g1.t2.png#x); }
+		`, ts)
+		s.KeepQuery = true
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(l01cVKU8.png?v=3) no-repeat; }
+	.bar { background: url(l01cVKU8.png) no-repeat -16px 0; }
+		`))
+	})
+
+	It("Normalize image path", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png":       "t1.png",
+			"image/g1.t2.png": "t2.png",
+		})
+		s := New(`
+	.foo { background: url(g1.t1.png); }
+	.bar { background: url(image/../g1.t1.png); }
+	.foobar { background: url(./image//g1.t2.png); }
+		`, ts)
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(l01cVKU8.png) no-repeat; }
+	.bar { background: url(l01cVKU8.png) no-repeat; }
+	.foobar { background: url(l01cVKU8.png) no-repeat -16px 0; }
+		`))
+		ts.assertSprite("l01cVKU8.png", 32, 16)
+	})
+
+	It("Rebase", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		s := New(`
+	@import "base.css";
+	@import 'theme/dark.css' screen;
+	@import url(print.css) print;
+	@font-face { src: url(fonts/a.woff?v=1); }
+	.foo { background: url(g1.t1.png); }
+	.bar { background: url(bar.png); }
+	.foo-bar { background: url(data:image/png;base64,AAAA); }
+	.quote:before { content: "a.css"; }
+		`, ts)
+		s.Rebase = "../src"
+		Ω(s.Gen()).Should(Equal(`
+	@import "../src/base.css";
+	@import "../src/theme/dark.css" screen;
+	@import url(../src/print.css) print;
+	@font-face { src: url(../src/fonts/a.woff?v=1); }
+	.foo { background: url(cobz_bF6.png) no-repeat; }
+	.bar { background: url(../src/bar.png); }
+	.foo-bar { background: url(data:image/png;base64,AAAA); }
+	.quote:before { content: "a.css"; }
+		`))
+	})
+
+	It("URL prefix", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+		})
+		css := `
+	.foo { background: url(g1.t1.png); }
+		`
+		s := New(css, ts)
+		s.URLPrefix = "../img"
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(../img/cobz_bF6.png) no-repeat; }
+		`))
+		ts.assertSprite("cobz_bF6.png", 16, 16)
+
+		s = New(css, ts)
+		s.URLPrefix = "https://cdn.example.com/img/"
+		Ω(s.Gen()).Should(Equal(`
+	.foo { background: url(https://cdn.example.com/img/cobz_bF6.png) no-repeat; }
+		`))
+	})
+
+	Context("Order", func() {
+		var ts *testService
+
+		BeforeEach(func() {
+			ts = newTestService(map[string]string{
+				"g1.b.png": "24.png",
+				"g1.c.png": "t1.png",
+				"g1.a.png": "t2.png",
+			})
+		})
+
+		gen := func(order StampOrder) string {
+			s := New(`
+	.foo { background: url(g1.c.png); }
+	.bar { background: url(g1.a.png); }
+	.foobar { background: url(g1.b.png); }
+	.foo-bar { background: url(g1.c.png); }`, ts)
+			s.Order = order
+			s.Namer = NamerFunc(func(group string, _ []byte) string {
+				return group + ".png"
+			})
+			css, _, err := s.Gen()
+			Ω(err).Should(Succeed())
+			ts.assertSprite("g1.png", 56, 24)
+			return css
+		}
+
+		It("CSS", func() {
+			Ω(gen(OrderCSS)).Should(Equal(`
+	.foo { background: url(g1.png) no-repeat; }
+	.bar { background: url(g1.png) no-repeat -16px 0; }
+	.foobar { background: url(g1.png) no-repeat -32px 0; }
+	.foo-bar { background: url(g1.png) no-repeat; }`))
+		})
+
+		It("Name", func() {
+			Ω(gen(OrderName)).Should(Equal(`
+	.foo { background: url(g1.png) no-repeat -40px 0; }
+	.bar { background: url(g1.png) no-repeat; }
+	.foobar { background: url(g1.png) no-repeat -16px 0; }
+	.foo-bar { background: url(g1.png) no-repeat -40px 0; }`))
+		})
+
+		It("Size", func() {
+			Ω(gen(OrderSize)).Should(Equal(`
+	.foo { background: url(g1.png) no-repeat -40px 0; }
+	.bar { background: url(g1.png) no-repeat -24px 0; }
+	.foobar { background: url(g1.png) no-repeat; }
+	.foo-bar { background: url(g1.png) no-repeat -40px 0; }`))
+		})
+
+		It("Parse", func() {
+			for _, o := range []StampOrder{OrderCSS, OrderName, OrderSize} {
+				Ω(ParseStampOrder(o.String())).Should(Equal(o))
+			}
+			_, err := ParseStampOrder("foo")
+			Ω(err).Should(HaveOccurred())
+		})
+
+	})
+
+	It("Share base", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+			"g2.t1.png": "24.png",
+		})
+		s := New(`@charset "utf-8";
+@import url(base.css);
+/* icons */
+.foo { background: url(g1.t1.png); }
+.bar,
+.foobar { color: red; background: url(g1.t2.png) }
+.foo-bar { background: url(g2.t1.png) !important; }
+@media print {
+	.baz { background: url(g1.t2.png); }
+}
+.foo { background: url(g1.t1.png); }
+`, ts)
+		s.ShareBase = true
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		Ω(s.Gen()).Should(Equal(`@charset "utf-8";
+@import url(base.css);
+/* icons */
+.foo, .bar, .foobar { background-image: url(g1.png); background-repeat: no-repeat; }
+.foo { background-position: 0 0; }
+.bar,
+.foobar { color: red; background-position: -16px 0 }
+.foo-bar { background: url(g2.png) no-repeat !important; }
+@media print {
+	.baz { background: url(g1.png) no-repeat -16px 0; }
+}
+.foo { background-position: 0 0; }
+`))
+	})
+
+	It("Share base, selector in multiple groups", func() {
+		ts := newTestService(map[string]string{
+			"g1.c.png": "t1.png",
+			"g2.b.png": "t2.png",
+			"g2.d.png": "24.png",
+		})
+		s := New(`.x { background: url(g2.b.png); }
+.x { background: url(g1.c.png); }
+.y { background: url(g2.d.png); }
+`, ts)
+		s.ShareBase = true
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		Ω(s.Gen()).Should(Equal(`.x { background: url(g2.png) no-repeat; }
+.x { background: url(g1.png) no-repeat; }
+.y { background-image: url(g2.png); background-repeat: no-repeat; }
+.y { background-position: -16px 0; }
+`))
+	})
+
+	It("Share base, after background shorthand", func() {
+		ts := newTestService(map[string]string{
+			"g.save.png": "t1.png",
+			"g.open.png": "t2.png",
+			"h.edit.png": "24.png",
+		})
+		s := New(`.btn { background: #ccc; }
+.icon-save { background: url(g.save.png); }
+.icon-open { background: url(g.open.png); }
+.toolbar { background: none; }
+.icon-edit { background: url(h.edit.png); }
+`, ts)
+		s.ShareBase = true
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		Ω(s.Gen()).Should(Equal(`.btn { background: #ccc; }
+.icon-save, .icon-open { background-image: url(g.png); background-repeat: no-repeat; }
+.icon-save { background-position: 0 0; }
+.icon-open { background-position: -16px 0; }
+.toolbar { background: none; }
+.icon-edit { background-image: url(h.png); background-repeat: no-repeat; }
+.icon-edit { background-position: 0 0; }
+`))
+	})
+
+	It("Images", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+		})
+		s := New(`
+	.a { background: url(g1.t1.png); }
+	.b { background: url(./g1.t2.png); }
+	.c { background: url(g1.t1.png); }
+	.d { background: url(other.png); }
+	.e { background: url(g2.t3.png); }
+		`, ts)
+		_, _, err := s.Gen()
+		Ω(err).Should(HaveOccurred())
+		Ω(s.Images()).Should(Equal([]string{"g1.t1.png", "g1.t2.png", "g2.t3.png"}))
+
+		ts.images["g2.t3.png"] = ts.images["g1.t1.png"]
+		_, _, err = s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(s.Images()).Should(Equal([]string{"g1.t1.png", "g1.t2.png", "g2.t3.png"}))
+	})
+
 	It("url() not after background", func() {
 		ts := newTestService(map[string]string{
 			"g1.t1.png": "t1.png",
@@ -179,12 +466,151 @@ var _ = Describe("sprite", func() {
 
 	XIt("background has more info than url()")
 
+	Context("Diagnostics", func() {
+
+		It("Image not exist", func() {
+			ts := newTestService(nil)
+			s := New(`
+	.foo { background: url(g1.t1.png); }`, ts)
+			_, diags, err := s.Gen()
+			Ω(err).Should(HaveOccurred())
+			Ω(diags).Should(HaveLen(1))
+			Ω(err.(*errors.Error).Err).Should(BeIdenticalTo(diags[0]))
+			Ω(*diags[0]).Should(MatchFields(IgnoreExtras, Fields{
+				"Severity": Equal(Error),
+				"Code":     Equal(CodeOpenImage),
+				"Line":     Equal(2),
+				"Column":   Equal(21),
+			}))
+			Ω(diags[0].String()).Should(HavePrefix("2:21: error: open image g1.t1.png: "))
+		})
+
+		It("Bad image", func() {
+			ts := newTestService(nil)
+			ts.images["g1.t1.png"] = []byte("not a png")
+			s := New(`.foo { background: url(g1.t1.png); }`, ts)
+			_, diags, err := s.Gen()
+			Ω(err).Should(HaveOccurred())
+			Ω(diags).Should(HaveLen(1))
+			Ω(diags[0].Code).Should(Equal(CodeDecodeImage))
+		})
+
+		It("Syntax error", func() {
+			s := New(`.foo { background: url('g1.t1.png); }`, newTestService(nil))
+			_, diags, err := s.Gen()
+			Ω(err).Should(HaveOccurred())
+			Ω(diags).Should(HaveLen(1))
+			Ω(diags[0].Code).Should(Equal(CodeSyntax))
+		})
+
+		It("Empty url", func() {
+			css := `.foo { background: url(); }`
+			s := New(css, newTestService(nil))
+			out, diags, err := s.Gen()
+			Ω(err).Should(Succeed())
+			Ω(out).Should(Equal(css))
+			Ω(diags).Should(HaveLen(1))
+			Ω(diags[0].String()).Should(Equal("1:20: warning: empty url, ignored [bad-url]"))
+		})
+
+		It("Absolute and remote url", func() {
+			css := `
+	.foo { background: url(/img/g1.t1.png); }
+	.bar { background: url(http://foo.com/g1.t1.png); }
+	.foobar { background: url(//foo.com/g1.t1.png); }
+		`
+			s := New(css, newTestService(nil))
+			out, diags, err := s.Gen()
+			Ω(err).Should(Succeed())
+			Ω(out).Should(Equal(css))
+			Ω(diags).Should(HaveLen(3))
+			Ω(diags[0].String()).Should(Equal("2:21: warning: image /img/g1.t1.png uses absolute path, ignored [absolute-url]"))
+			Ω(diags[1].Code).Should(Equal(CodeRemoteURL))
+			Ω(diags[2].Code).Should(Equal(CodeRemoteURL))
+		})
+
+	})
+
+	Context("Write errors", func() {
+		var (
+			ts *testService
+			w  *failWriter
+		)
+
+		BeforeEach(func() {
+			ts = newTestService(map[string]string{"g1.t1.png": "t1.png"})
+			w = &failWriter{}
+		})
+
+		gen := func() error {
+			s := New(`.foo { background: url(g1.t1.png); }`, &failService{ts, w})
+			_, _, err := s.Gen()
+			return err
+		}
+
+		It("Close error returned", func() {
+			w.closeErr = fmt.Errorf("disk full")
+			err := gen()
+			Ω(err).Should(MatchError(ContainSubstring("disk full")))
+			Ω(errors.GetCausedBy(err)).Should(Equal(errors.ByRuntime))
+			Ω(w.closed).Should(BeTrue())
+			Ω(w.aborted).Should(BeFalse())
+		})
+
+		It("Abort on write error", func() {
+			w.writeErr = fmt.Errorf("disk full")
+			err := gen()
+			Ω(err).Should(MatchError(ContainSubstring("disk full")))
+			Ω(errors.GetCausedBy(err)).Should(Equal(errors.ByRuntime))
+			Ω(w.aborted).Should(BeTrue())
+			Ω(w.closed).Should(BeFalse())
+		})
+
+		It("Closed on success", func() {
+			Ω(gen()).Should(Succeed())
+			Ω(w.closed).Should(BeTrue())
+		})
+
+	})
+
 })
 
-// Implement Service interface for testing
+// Service creates sprite images by w.
+type failService struct {
+	*testService
+	w *failWriter
+}
+
+func (s *failService) CreateSpriteImage(path string) (io.Writer, error) {
+	return s.w, nil
+}
+
+// Writer fails Write() or Close() by writeErr and closeErr.
+type failWriter struct {
+	writeErr, closeErr error
+	closed, aborted    bool
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.writeErr != nil {
+		return 0, w.writeErr
+	}
+	return len(p), nil
+}
+
+func (w *failWriter) Close() error {
+	w.closed = true
+	return w.closeErr
+}
+
+func (w *failWriter) Abort() error {
+	w.aborted = true
+	return nil
+}
+
+// MemoryService with test helpers.
 type testService struct {
-	images  map[string][]byte        // source images path -> image
-	sprites map[string]*bytes.Buffer // created sprites
+	*MemoryService
 }
 
 // images: filename -> resource name
@@ -195,24 +621,7 @@ func newTestService(images map[string]string) *testService {
 		Ω(err).Should(Succeed())
 		imgs[filename] = content
 	}
-	return &testService{
-		images:  imgs,
-		sprites: make(map[string]*bytes.Buffer),
-	}
-}
-
-func (s *testService) OpenImage(path string) (io.Reader, error) {
-	r := s.images[path]
-	if r == nil {
-		return nil, fmt.Errorf("file %s not exist", path)
-	}
-	return bytes.NewReader(r), nil
-}
-
-func (s *testService) CreateSpriteImage(path string) (io.Writer, error) {
-	r := &bytes.Buffer{}
-	s.sprites[path] = r
-	return r, nil
+	return &testService{NewMemoryService(imgs)}
 }
 
 func (s *testService) assertSprite(path string, width, height int) {
@@ -0,0 +1,121 @@
+package sprite
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats of a generated sprite, returned by Spriter.Stats().
+type Stats struct {
+	Group string `json:"group"`
+
+	// Images is the number of distinct images in sprite.
+	Images int `json:"images"`
+
+	// RequestsSaved is the number of HTTP requests saved by the sprite,
+	// Images - 1.
+	RequestsSaved int `json:"requestsSaved"`
+
+	// SourceBytes is the total file size of source images, SpriteBytes is the
+	// size of encoded sprite image.
+	SourceBytes int64 `json:"sourceBytes"`
+	SpriteBytes int64 `json:"spriteBytes"`
+
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// WastedArea is the percentage of fully transparent pixels in sprite,
+	// including area not covered by any image.
+	WastedArea float64 `json:"wastedArea"`
+
+	// Time spent decoding source images, packing them into sprite, and
+	// encoding sprite image, marshaled to JSON in nanoseconds.
+	DecodeTime time.Duration `json:"decodeTime"`
+	PackTime   time.Duration `json:"packTime"`
+	EncodeTime time.Duration `json:"encodeTime"`
+
+	// Cached is true if sprite loaded from Spriter.Cache, timings are zero.
+	Cached bool `json:"cached"`
+}
+
+// Stats returns statistics of sprites generated by last Gen() call, ordered
+// by group name.
+func (s *Spriter) Stats() []*Stats {
+	return s.stats
+}
+
+func newStats(group string, sprite *image.RGBA, sts []*stamp, spriteBytes int) *Stats {
+	size := sprite.Bounds().Size()
+	st := &Stats{
+		Group:         group,
+		Images:        len(sts),
+		RequestsSaved: len(sts) - 1,
+		SpriteBytes:   int64(spriteBytes),
+		Width:         size.X,
+		Height:        size.Y,
+		WastedArea:    transparentPercent(sprite),
+	}
+	for _, stamp := range sts {
+		st.SourceBytes += stamp.fileSize
+		st.DecodeTime += stamp.decodeTime
+	}
+	return st
+}
+
+// Returns percentage of fully transparent pixels of img.
+func transparentPercent(img *image.RGBA) float64 {
+	b := img.Bounds()
+	total := b.Dx() * b.Dy()
+	if total == 0 {
+		return 0
+	}
+
+	transparent := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.RGBAAt(x, y).A == 0 {
+				transparent++
+			}
+		}
+	}
+	return float64(transparent) * 100 / float64(total)
+}
+
+// WriteStats writes stats as a table to w, one row for each sprite, followed
+// by a total row.
+func WriteStats(w io.Writer, stats []*Stats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "group\timages\tsaved\tsource\tsprite\tsize\twasted\tdecode\tpack\tencode")
+
+	total, area := Stats{Group: "total"}, 0
+	for _, st := range stats {
+		writeStatsRow(tw, st, fmt.Sprintf("%dx%d", st.Width, st.Height))
+		total.Images += st.Images
+		total.RequestsSaved += st.RequestsSaved
+		total.SourceBytes += st.SourceBytes
+		total.SpriteBytes += st.SpriteBytes
+		total.DecodeTime += st.DecodeTime
+		total.PackTime += st.PackTime
+		total.EncodeTime += st.EncodeTime
+		total.WastedArea += st.WastedArea * float64(st.Width*st.Height)
+		area += st.Width * st.Height
+	}
+	if area != 0 {
+		total.WastedArea /= float64(area)
+	}
+	writeStatsRow(tw, &total, "")
+	return tw.Flush()
+}
+
+func writeStatsRow(w io.Writer, st *Stats, size string) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%.1f%%\t%s\t%s\t%s\n",
+		st.Group, st.Images, st.RequestsSaved, st.SourceBytes, st.SpriteBytes, size,
+		st.WastedArea, roundDuration(st.DecodeTime), roundDuration(st.PackTime), roundDuration(st.EncodeTime))
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
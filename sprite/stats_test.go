@@ -0,0 +1,77 @@
+package sprite
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+var _ = Describe("Stats", func() {
+
+	It("Collect stats", func() {
+		ts := newTestService(map[string]string{
+			"g1.t1.png": "t1.png",
+			"g1.t2.png": "t2.png",
+			"g2.t3.png": "t3.png",
+		})
+		s := New(`
+	.a { background: url(g1.t1.png); }
+	.b { background: url(g1.t2.png); }
+	.c { background: url(g1.t1.png); }
+	.d { background: url(g2.t3.png); }
+		`, ts)
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		_, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+
+		stats := s.Stats()
+		Ω(stats).Should(HaveLen(2))
+		Ω(*stats[0]).Should(MatchFields(IgnoreExtras, Fields{
+			"Group":         Equal("g1"),
+			"Images":        Equal(2),
+			"RequestsSaved": Equal(1),
+			"SourceBytes":   BeEquivalentTo(701 + 607),
+			"SpriteBytes":   BeEquivalentTo(ts.sprites["g1.png"].Len()),
+			"Width":         Equal(32),
+			"Height":        Equal(16),
+			"WastedArea":    And(BeNumerically(">", 0), BeNumerically("<", 100)),
+		}))
+		Ω(*stats[1]).Should(MatchFields(IgnoreExtras, Fields{
+			"Group":         Equal("g2"),
+			"Images":        Equal(1),
+			"RequestsSaved": Equal(0),
+			"SourceBytes":   BeEquivalentTo(611),
+		}))
+	})
+
+	It("Transparent percent", func() {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+		Ω(transparentPercent(img)).Should(Equal(100.0))
+		img.Set(0, 0, color.Black)
+		img.Set(3, 1, color.Black)
+		Ω(transparentPercent(img)).Should(Equal(75.0))
+		Ω(transparentPercent(image.NewRGBA(image.Rect(0, 0, 0, 0)))).Should(Equal(0.0))
+	})
+
+	It("Write stats", func() {
+		buf := bytes.Buffer{}
+		Ω(WriteStats(&buf, []*Stats{
+			{"g1", 3, 2, 3000, 1200, 48, 16, 50, time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, false},
+			{"g2", 1, 0, 600, 500, 16, 16, 10, 0, 0, 0, false},
+		})).Should(Succeed())
+		Ω(buf.String()).Should(Equal(
+			`group  images  saved  source  sprite  size   wasted  decode  pack  encode
+g1     3       2      3000    1200    48x16  50.0%   1ms     2ms   3ms
+g2     1       0      600     500     16x16  10.0%   0s      0s    0s
+total  4       2      3600    1700           40.0%   1ms     2ms   3ms
+`))
+	})
+
+})
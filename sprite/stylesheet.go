@@ -0,0 +1,94 @@
+package sprite
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/redforks/css/writer"
+	"github.com/redforks/errors"
+)
+
+// ImageLister is implemented by Service able to list its image files, needed
+// by NewFromImages().
+type ImageLister interface {
+	// ListImages returns slash separated paths of all image files, can be
+	// opened by Service.OpenImage().
+	ListImages() ([]string, error)
+}
+
+const (
+	// DefaultSelector is the default selector template of each image in
+	// stylesheet generated by NewFromImages() Spriter.
+	DefaultSelector = ".icon-[group]-[name]"
+
+	// DefaultBaseSelector is the default selector template of the shared
+	// base class of each group, carrying sprite url.
+	DefaultBaseSelector = ".icon-[group]"
+)
+
+// NewFromImages creates a Spriter generates sprites for all png images listed
+// by service, which must implement ImageLister. There is no input css, images
+// are grouped by their file names as usual, and Gen() returns a fresh
+// stylesheet, see WriteStylesheet(). Diagnostics of images have no position,
+// Line and Column are 0.
+//
+//  selector: selector template of each image, such as DefaultSelector.
+//  baseSelector: selector template of the base class of each group, such as
+//  DefaultBaseSelector.
+func NewFromImages(service Service, selector, baseSelector string) (*Spriter, error) {
+	if selector == "" || baseSelector == "" {
+		return nil, errors.Inputf("empty selector template")
+	}
+	lister, ok := service.(ImageLister)
+	if !ok {
+		return nil, errors.Bug("Service not implement ImageLister")
+	}
+
+	images, err := lister.ListImages()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(images)
+
+	s := New("", service)
+	s.fromImages, s.listed = true, images
+	s.selector, s.baseSelector = selector, baseSelector
+	return s, nil
+}
+
+// WriteStylesheet returns stylesheet of sheets. For each sprite group, a base
+// rule carrying sprite url, its selector expanded from baseSelector; each
+// image has a rule for its position and size, selector expanded from
+// selector. Placeholders "[group]" and "[name]" in selector templates are
+// replaced by group and image name.
+func WriteStylesheet(sheets []*Sheet, selector, baseSelector string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("/* Generated by spriter, DO NOT EDIT! */\n")
+	for _, sheet := range sheets {
+		fmt.Fprintf(buf, "\n%s {\n  background-image: %s;\n  background-repeat: no-repeat;\n}\n",
+			expandSelector(baseSelector, sheet.Group, ""), writer.FormatURI(sheet.URL))
+		for _, st := range sheet.Stamps {
+			fmt.Fprintf(buf, "\n%s {\n  background-position: %s;\n  width: %dpx;\n  height: %dpx;\n}\n",
+				expandSelector(selector, sheet.Group, st.Name), position(st.X, st.Y), st.Width, st.Height)
+		}
+	}
+	return buf.String()
+}
+
+// Returns css background-position of image at x, y inside sprite.
+func position(x, y int) string {
+	return pxOffset(x) + " " + pxOffset(y)
+}
+
+func pxOffset(v int) string {
+	if v == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%dpx", -v)
+}
+
+func expandSelector(template, group, name string) string {
+	return strings.NewReplacer("[group]", cssIdent(group), "[name]", cssIdent(name)).Replace(template)
+}
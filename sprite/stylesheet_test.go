@@ -0,0 +1,94 @@
+package sprite
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stylesheet", func() {
+
+	It("Generate from images", func() {
+		ts := newTestService(map[string]string{
+			"g1.a.png":     "24.png",
+			"img/g1.b.png": "t2.png",
+			"g2.c d.png":   "t1.png",
+			"foo.png":      "t1.png",
+		})
+		s, err := NewFromImages(ts, DefaultSelector, DefaultBaseSelector)
+		Ω(err).Should(Succeed())
+		s.URLPrefix = "../img"
+		s.Namer = NamerFunc(func(group string, _ []byte) string {
+			return group + ".png"
+		})
+		Ω(s.Gen()).Should(Equal(`/* Generated by spriter, DO NOT EDIT! */
+
+.icon-g1 {
+  background-image: url(../img/g1.png);
+  background-repeat: no-repeat;
+}
+
+.icon-g1-a {
+  background-position: 0 0;
+  width: 24px;
+  height: 24px;
+}
+
+.icon-g1-b {
+  background-position: -24px 0;
+  width: 16px;
+  height: 16px;
+}
+
+.icon-g2 {
+  background-image: url(../img/g2.png);
+  background-repeat: no-repeat;
+}
+
+.icon-g2-c-d {
+  background-position: 0 0;
+  width: 16px;
+  height: 16px;
+}
+`))
+		ts.assertSprite("g1.png", 40, 24)
+		ts.assertSprite("g2.png", 16, 16)
+	})
+
+	It("Selector template", func() {
+		ts := newTestService(map[string]string{
+			"g1.a.png": "t1.png",
+		})
+		s, err := NewFromImages(ts, "i.[group]_[name]", "i.[group]")
+		Ω(err).Should(Succeed())
+		css, _, err := s.Gen()
+		Ω(err).Should(Succeed())
+		Ω(css).Should(ContainSubstring("\ni.g1 {\n"))
+		Ω(css).Should(ContainSubstring("\ni.g1_a {\n"))
+	})
+
+	It("Empty selector", func() {
+		ts := newTestService(map[string]string{"g1.a.png": "t1.png"})
+		_, err := NewFromImages(ts, "", DefaultBaseSelector)
+		Ω(err).Should(HaveOccurred())
+		_, err = NewFromImages(ts, DefaultSelector, "")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("Diagnostics without position", func() {
+		ts := newTestService(nil)
+		ts.images["g1.a.png"] = []byte("not a png")
+		s, err := NewFromImages(ts, DefaultSelector, DefaultBaseSelector)
+		Ω(err).Should(Succeed())
+		_, diags, err := s.Gen()
+		Ω(err).Should(HaveOccurred())
+		Ω(diags).Should(HaveLen(1))
+		Ω(diags[0].String()).Should(HavePrefix("error: decode image g1.a.png"))
+		Ω(diags[0].String()).Should(HaveSuffix("[decode-image]"))
+	})
+
+	It("Service not ImageLister", func() {
+		_, err := NewFromImages(struct{ Service }{newTestService(nil)}, DefaultSelector, DefaultBaseSelector)
+		Ω(err).Should(HaveOccurred())
+	})
+
+})
@@ -0,0 +1,189 @@
+package sprite
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var errEmptyURL = fmt.Errorf("empty url")
+
+// extractUriFile returns the file referenced by url token value, css escapes
+// and percent-encoding are decoded. Query string (without leading '?') is
+// returned separately, fragment is dropped.
+func extractUriFile(uri string) (file, query string, err error) {
+	var s string
+	if s, err = parseURI(uri); err != nil {
+		return
+	}
+
+	s, query = splitQuery(s)
+	if s == "" {
+		return "", "", errEmptyURL
+	}
+	if file, err = url.PathUnescape(s); err != nil {
+		return "", "", fmt.Errorf("%s: %v", uri, err)
+	}
+	return
+}
+
+// Split url into path and query string, fragment is dropped.
+func splitQuery(s string) (path, query string) {
+	if i := strings.IndexByte(s, '#'); i != -1 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '?'); i != -1 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// rebaseURI prefixes relative url in url token value with base, returns the
+// new url and true. Returns false if url is absolute, remote, has scheme such
+// as "data:", fragment only, or can not be parsed.
+func rebaseURI(tk, base string) (string, bool) {
+	uri, err := parseURI(tk)
+	if err != nil || uri[0] == '/' || uri[0] == '#' || hasScheme(uri) {
+		return "", false
+	}
+
+	p, suffix := uri, ""
+	if i := strings.IndexAny(uri, "?#"); i != -1 {
+		p, suffix = uri[:i], uri[i:]
+	}
+	if p == "" {
+		return "", false
+	}
+	return path.Join(base, p) + suffix, true
+}
+
+// Returns true if uri starts with a scheme, such as "http:", "data:".
+func hasScheme(uri string) bool {
+	for i := 0; i < len(uri); i++ {
+		c := uri[i]
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		case i > 0 && c == ':':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// parseURI parses css url token value such as `url( "foo.png" )`, returns
+// the url inside it, quotes and surrounding whitespace removed, css escapes
+// decoded. Percent-encoding is not touched.
+func parseURI(tk string) (string, error) {
+	if len(tk) < 5 || !strings.EqualFold(tk[:4], "url(") || tk[len(tk)-1] != ')' {
+		return "", fmt.Errorf("malformed url token %s", tk)
+	}
+
+	body := tk[4 : len(tk)-1]
+	i := skipSpace(body, 0)
+	if i == len(body) {
+		return "", errEmptyURL
+	}
+
+	var (
+		buf   strings.Builder
+		quote byte
+	)
+	if c := body[i]; c == '"' || c == '\'' {
+		quote = c
+		i++
+	}
+
+	for i < len(body) {
+		c := body[i]
+		switch {
+		case quote != 0 && c == quote:
+			if skipSpace(body, i+1) != len(body) {
+				return "", fmt.Errorf("unexpected content after quoted url in %s", tk)
+			}
+			return nonEmptyURL(buf.String())
+		case quote == 0 && isSpace(c):
+			if skipSpace(body, i) != len(body) {
+				return "", fmt.Errorf("unescaped whitespace in %s", tk)
+			}
+			return nonEmptyURL(buf.String())
+		case c == '\\':
+			i = unescape(body, i+1, &buf)
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+
+	if quote != 0 {
+		return "", fmt.Errorf("unclosed quotation mark in %s", tk)
+	}
+	return nonEmptyURL(buf.String())
+}
+
+func nonEmptyURL(s string) (string, error) {
+	if s == "" {
+		return "", errEmptyURL
+	}
+	return s, nil
+}
+
+// Decode css escape sequence starts at s[i] (the char after backslash) into
+// buf, returns index of the next char after the escape sequence.
+func unescape(s string, i int, buf *strings.Builder) int {
+	if i == len(s) {
+		return i
+	}
+
+	if s[i] == '\n' {
+		// line continuation
+		return i + 1
+	}
+
+	end := i
+	for end < len(s) && end-i < 6 && isHex(s[end]) {
+		end++
+	}
+	if end == i {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		buf.WriteRune(r)
+		return i + size
+	}
+
+	code, _ := strconv.ParseUint(s[i:end], 16, 32)
+	r := rune(code)
+	if r == 0 || !utf8.ValidRune(r) {
+		r = utf8.RuneError
+	}
+	buf.WriteRune(r)
+
+	// a single whitespace after hex escape is part of the escape
+	if end < len(s) && isSpace(s[end]) {
+		end++
+	}
+	return end
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
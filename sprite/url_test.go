@@ -0,0 +1,83 @@
+package sprite
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("url", func() {
+
+	DescribeTable("extractUriFile", func(uri, exp string) {
+		Ω(extractUriFile(uri)).Should(Equal(exp))
+	},
+		Entry("plain", "url(g.a.png)", "g.a.png"),
+		Entry("upper case", "URL(g.a.png)", "g.a.png"),
+		Entry("single quote", "url('g.a.png')", "g.a.png"),
+		Entry("double quote", `url("g.a.png")`, "g.a.png"),
+		Entry("whitespace", "url( g.a.png\t)", "g.a.png"),
+		Entry("whitespace around quoted", `url( "g.a b.png" )`, "g.a b.png"),
+		Entry("escaped space", `url(g.a\ b.png)`, "g.a b.png"),
+		Entry("escaped paren", `url(g.a\(1\).png)`, "g.a(1).png"),
+		Entry("hex escape", `url(g.\61 .png)`, "g.a.png"),
+		Entry("hex escape 6 digits", `url(g.\000061.png)`, "g.a.png"),
+		Entry("escaped quote", `url("g.a\".png")`, `g.a".png`),
+		Entry("line continuation", "url(\"g.a\\\n.png\")", "g.a.png"),
+		Entry("percent encoding", `url("g.a%20b.png")`, "g.a b.png"),
+		Entry("zero escape", `url(g.\0 .png)`, "g.�.png"),
+		Entry("fragment", `url(g.a.png#x)`, "g.a.png"),
+		Entry("encoded question mark", `url(g.a%3F.png)`, "g.a?.png"),
+	)
+
+	DescribeTable("extractUriFile query", func(uri, expFile, expQuery string) {
+		file, query, err := extractUriFile(uri)
+		Ω(err).Should(Succeed())
+		Ω(file).Should(Equal(expFile))
+		Ω(query).Should(Equal(expQuery))
+	},
+		Entry("query", `url(g.a.png?v=3)`, "g.a.png", "v=3"),
+		Entry("query and fragment", `url("g.a.png?v=3#x")`, "g.a.png", "v=3"),
+		Entry("empty query", `url(g.a.png?)`, "g.a.png", ""),
+		Entry("query not decoded", `url(g.a%20b.png?a=%20)`, "g.a b.png", "a=%20"),
+	)
+
+	DescribeTable("extractUriFile error", func(uri string) {
+		_, _, err := extractUriFile(uri)
+		Ω(err).Should(HaveOccurred())
+	},
+		Entry("empty", "url()"),
+		Entry("blank", "url(  )"),
+		Entry("empty string", `url("")`),
+		Entry("unclosed quote", `url("g.a.png)`),
+		Entry("space inside unquoted", `url(g.a b.png)`),
+		Entry("content after quote", `url("g.a.png"b)`),
+		Entry("bad percent encoding", `url(g.a%2.png)`),
+		Entry("not url", `foo(g.a.png)`),
+		Entry("only query", `url(?v=1)`),
+	)
+
+	DescribeTable("rebaseURI", func(uri, exp string) {
+		rebased, ok := rebaseURI(uri, "../src")
+		Ω(ok).Should(BeTrue())
+		Ω(rebased).Should(Equal(exp))
+	},
+		Entry("relative", "url(a.png)", "../src/a.png"),
+		Entry("sub dir", `url("img/a b.png")`, "../src/img/a b.png"),
+		Entry("parent dir", "url(../img/a.png)", "../img/a.png"),
+		Entry("query and fragment", "url(font.woff?v=1#iefix)", "../src/font.woff?v=1#iefix"),
+		Entry("percent encoding kept", "url(a%20b.png)", "../src/a%20b.png"),
+	)
+
+	DescribeTable("rebaseURI untouched", func(uri string) {
+		_, ok := rebaseURI(uri, "../src")
+		Ω(ok).Should(BeFalse())
+	},
+		Entry("absolute", "url(/img/a.png)"),
+		Entry("remote", "url(http://foo.com/a.png)"),
+		Entry("protocol relative", "url(//foo.com/a.png)"),
+		Entry("data", "url(data:image/png;base64,AAAA)"),
+		Entry("fragment", "url(#filter)"),
+		Entry("empty", "url()"),
+	)
+
+})
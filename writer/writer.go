@@ -2,7 +2,9 @@ package writer
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/redforks/css-1/scanner"
 )
@@ -52,3 +54,37 @@ func Dumps(tokens []*scanner.Token) (string, error) {
 	}
 	return string(buf.Bytes()), nil
 }
+
+// FormatURI returns css url token value of uri, such as `url(foo.png)`. uri is
+// quoted and escaped if it contains whitespace, quotes, parentheses, backslash
+// or control characters.
+func FormatURI(uri string) string {
+	if !strings.ContainsAny(uri, " \"'()\\") && strings.IndexFunc(uri, isControl) == -1 {
+		return "url(" + uri + ")"
+	}
+	return "url(" + FormatString(uri) + ")"
+}
+
+// FormatString returns css string token value of s, double quoted, quotes,
+// backslash and control characters escaped.
+func FormatString(s string) string {
+	buf := bytes.Buffer{}
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case isControl(r):
+			fmt.Fprintf(&buf, "\\%x ", r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -254,4 +254,46 @@ var _ = Describe("Writer", func() {
 		Ω(Dumps(tokens)).Should(Equal(css))
 	})
 
+	Context("FormatURI", func() {
+
+		It("Plain", func() {
+			Ω(FormatURI("img/foo.png")).Should(Equal("url(img/foo.png)"))
+		})
+
+		It("Quote special chars", func() {
+			Ω(FormatURI("a b.png")).Should(Equal(`url("a b.png")`))
+			Ω(FormatURI("a(1).png")).Should(Equal(`url("a(1).png")`))
+			Ω(FormatURI(`a'"\.png`)).Should(Equal(`url("a'\"\\.png")`))
+		})
+
+		It("Escape control chars", func() {
+			Ω(FormatURI("a\nb.png")).Should(Equal(`url("a\a b.png")`))
+		})
+
+		It("Scanner reads back", func() {
+			uri := FormatURI(`a "b" (c).png`)
+			tk := scanner.New(uri).Next()
+			Ω(tk.Type).Should(Equal(scanner.TokenURI))
+			Ω(tk.Value).Should(Equal(uri))
+		})
+
+	})
+
+	Context("FormatString", func() {
+
+		It("Quote and escape", func() {
+			Ω(FormatString("a.css")).Should(Equal(`"a.css"`))
+			Ω(FormatString(`a'"\.css`)).Should(Equal(`"a'\"\\.css"`))
+			Ω(FormatString("a\nb.css")).Should(Equal(`"a\a b.css"`))
+		})
+
+		It("Scanner reads back", func() {
+			s := FormatString(`a "b".css`)
+			tk := scanner.New(s).Next()
+			Ω(tk.Type).Should(Equal(scanner.TokenString))
+			Ω(tk.Value).Should(Equal(s))
+		})
+
+	})
+
 })